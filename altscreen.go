@@ -0,0 +1,192 @@
+package terminal
+
+import (
+	"slices"
+	"strings"
+)
+
+// AltScreenPolicy controls what happens to the contents of the alternate
+// screen buffer (used by full-screen programs such as vim, less and top) when
+// the program switches back to the main screen.
+type AltScreenPolicy int
+
+const (
+	// AltScreenDiscard throws away the alternate screen contents, as most
+	// terminals do.
+	AltScreenDiscard AltScreenPolicy = iota
+
+	// AltScreenSnapshot keeps the final contents of the alternate screen,
+	// inserted as ordinary lines above the cursor.
+	AltScreenSnapshot
+
+	// AltScreenInline keeps the final contents of the alternate screen,
+	// inserted above the cursor as a collapsed <details> block.
+	AltScreenInline
+)
+
+// WithAltScreenPolicy sets what happens to the alternate screen contents.
+func WithAltScreenPolicy(p AltScreenPolicy) ScreenOption {
+	return func(s *Screen) error {
+		s.altScreenPolicy = p
+		return nil
+	}
+}
+
+// savedScreen is the state of the main screen while the alternate screen is
+// in use.
+type savedScreen struct {
+	screen []screenLine
+	x, y   int
+}
+
+// setMode handles SM and RM (CSI h and CSI l), and their DEC private
+// equivalents DECSET and DECRST (CSI ? h and CSI ? l).
+func (s *Screen) setMode(set bool, instructions []string) {
+	if len(instructions) == 0 || !strings.HasPrefix(instructions[0], "?") {
+		// Other modes (e.g. insert mode) aren't relevant.
+		return
+	}
+	for i, mode := range instructions {
+		if i == 0 {
+			mode = mode[1:]
+		}
+		switch mode {
+		case "47", "1047", "1049":
+			// Alternate screen buffer. 1049 also saves and restores the
+			// cursor, and 1047 clears the alternate screen on exit. Since the
+			// alternate screen always starts out blank here, there is no
+			// difference between 47 and 1047.
+			if set {
+				s.enterAltScreen()
+			} else {
+				s.exitAltScreen(mode == "1049")
+			}
+
+		case "6":
+			// Origin mode (DECOM), which also moves the cursor to the top left
+			// (of the scroll region, if set). As with DECSTBM, only do that if
+			// rows are known.
+			s.originMode = set
+			if s.absoluteRows() {
+				s.moveTo(0, 0)
+			}
+
+		default:
+			// Lots of other modes are not relevant, e.g.
+			// - show/hide cursor
+			// - enable/disable focus reporting
+			// - bracketed paste mode
+		}
+	}
+}
+
+// enterAltScreen switches to a blank alternate screen.
+func (s *Screen) enterAltScreen() {
+	if s.mainScreen != nil {
+		// Already using the alternate screen.
+		return
+	}
+	s.mainScreen = &savedScreen{
+		screen: s.screen,
+		x:      s.x,
+		y:      s.y,
+	}
+	s.screen = nil
+	s.scrollTop, s.scrollBottom = 0, 0
+}
+
+// exitAltScreen switches back to the main screen, and deals with the contents
+// of the alternate screen according to the policy.
+func (s *Screen) exitAltScreen(restoreCursor bool) {
+	if s.mainScreen == nil {
+		// Not using the alternate screen.
+		return
+	}
+	alt := s.screen
+	main := s.mainScreen
+	s.mainScreen = nil
+
+	s.screen = main.screen
+	s.scrollTop, s.scrollBottom = 0, 0
+	if restoreCursor {
+		s.x, s.y = main.x, main.y
+	}
+	s.x = min(s.x, s.cols-1)
+	s.y = min(s.y, s.lines-1)
+
+	kept := s.altScreenLines(alt)
+	if kept == nil {
+		// Recycle the alternate screen storage.
+		for _, l := range alt {
+			s.nodeRecycling = append(s.nodeRecycling, l.nodes[:0])
+		}
+		return
+	}
+	s.insertLinesAboveCursor(kept)
+}
+
+// altScreenLines returns the lines to keep from the alternate screen,
+// according to the policy, or nil if nothing should be kept.
+func (s *Screen) altScreenLines(alt []screenLine) []screenLine {
+	// Blank lines at the bottom of the alternate screen aren't interesting.
+	for len(alt) > 0 && len(alt[len(alt)-1].nodes) == 0 {
+		alt = alt[:len(alt)-1]
+	}
+	if len(alt) == 0 {
+		return nil
+	}
+
+	switch s.altScreenPolicy {
+	case AltScreenSnapshot:
+		// The last line might have been wrapped onto a line that is now gone.
+		alt = slices.Clone(alt)
+		alt[len(alt)-1].newline = true
+		return alt
+
+	case AltScreenInline:
+		return []screenLine{{
+			nodes:    []node{{style: sbElement}},
+			newline:  true,
+			elements: []*element{{elementType: elementAltScreen, lines: alt}},
+		}}
+	}
+	return nil
+}
+
+// insertLinesAboveCursor inserts lines into the buffer above the line the
+// cursor is on, keeping the cursor on the same line.
+func (s *Screen) insertLinesAboveCursor(lines []screenLine) {
+	// The cursor line might not exist in the buffer yet.
+	cursor := s.top() + s.y
+	at := min(cursor, len(s.screen))
+	s.screen = slices.Insert(s.screen, at, lines...)
+	cursor += len(lines)
+
+	// Trim the buffer back down to size, if needed.
+	if limit := s.bufferLimit(); limit > 0 {
+		for len(s.screen) > limit {
+			n := len(s.screen)
+			s.scrollOut()
+			cursor -= n - len(s.screen)
+		}
+	}
+
+	// The window may have moved, so recompute the cursor position within it.
+	// Like after a newline, the cursor can end up just below the window, in
+	// which case a new line is added when something is written.
+	s.y = max(0, min(cursor-s.top(), s.lines))
+}
+
+// contents returns the lines to render. Usually this is the buffer, but if
+// the alternate screen is still in use, it is the main screen followed by
+// whatever the policy says to keep from the alternate screen.
+func (s *Screen) contents() []screenLine {
+	if s.mainScreen == nil {
+		return s.screen
+	}
+	lines := s.mainScreen.screen
+	if kept := s.altScreenLines(s.screen); kept != nil {
+		lines = append(lines[:len(lines):len(lines)], kept...)
+	}
+	return lines
+}
@@ -0,0 +1,133 @@
+package terminal
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestAltScreen(t *testing.T) {
+	tests := []struct {
+		name   string
+		policy AltScreenPolicy
+		input  string
+		want   string
+	}{
+		{
+			name:   "discard with 1049",
+			policy: AltScreenDiscard,
+			input:  "$ vim\n\x1b[?1049h\x1b[Hfile contents\n~\n~\x1b[?1049l$ done",
+			want:   "$ vim\n$ done",
+		},
+		{
+			name:   "discard with 47",
+			policy: AltScreenDiscard,
+			input:  "before\n\x1b[?47hfull screen\x1b[?47l\rafter",
+			want:   "before\nafter",
+		},
+		{
+			name:   "1049 restores the cursor",
+			policy: AltScreenDiscard,
+			input:  "one\ntwo\x1b[?1049h\x1b[2J\x1b[5;5Hstuff\x1b[?1049l!",
+			want:   "one\ntwo!",
+		},
+		{
+			name:   "snapshot",
+			policy: AltScreenSnapshot,
+			input:  "$ less\n\x1b[?1049h\x1b[H\x1b[31mline 1\x1b[0m\r\nline 2\r\n\x1b[7m(END)\x1b[0m\x1b[?1049l$ done",
+			want:   "$ less\n<span class=\"term-fg31\">line 1</span>\nline 2\n<span class=\"term-fg7\">(END)</span>\n$ done",
+		},
+		{
+			name:   "inline",
+			policy: AltScreenInline,
+			input:  "$ top\n\x1b[?1049h\x1b[Hload average: 0.1\r\nPID USER\x1b[?1049l$ done",
+			want:   "$ top\n<details class=\"term-alt-screen\"><summary>Alternate screen</summary>load average: 0.1\nPID USER</details>\n$ done",
+		},
+		{
+			name:   "blank alternate screens are not kept",
+			policy: AltScreenInline,
+			input:  "before\n\x1b[?1049h\x1b[2J\x1b[?1049lafter",
+			want:   "before\nafter",
+		},
+		{
+			name:   "alternate screen still in use at the end",
+			policy: AltScreenSnapshot,
+			input:  "before\n\x1b[?1049h\x1b[Hstill going",
+			want:   "before\nstill going",
+		},
+		{
+			name:   "alternate screen has no scrollback",
+			policy: AltScreenSnapshot,
+			input:  "before\n\x1b[?1049h\x1b[H1\n2\n3\n4\n5\x1b[?1049lafter",
+			want:   "before\n3\n4\n5\nafter",
+		},
+		{
+			name:   "origin mode positions within the scroll region",
+			policy: AltScreenSnapshot,
+			input:  "before\n\x1b[?1049h\x1b[1;2r\x1b[?6h\x1b[5;2HX\x1b[?1049lafter",
+			want:   "before\n&nbsp;\n X\nafter",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			s, err := NewScreen(WithSize(80, 3), WithAltScreenPolicy(test.policy))
+			if err != nil {
+				t.Fatalf("NewScreen() error = %v", err)
+			}
+			s.Write([]byte(test.input))
+			if diff := cmp.Diff(s.AsHTML(), test.want); diff != "" {
+				t.Errorf("AsHTML() diff (-got +want):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestAltScreenPlainText(t *testing.T) {
+	tests := []struct {
+		name   string
+		policy AltScreenPolicy
+		want   string
+	}{
+		{name: "discard", policy: AltScreenDiscard, want: "$ top\n$ done"},
+		{name: "snapshot", policy: AltScreenSnapshot, want: "$ top\nload average: 0.1\nPID USER\n$ done"},
+		{name: "inline", policy: AltScreenInline, want: "$ top\nload average: 0.1\nPID USER\n$ done"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			s, err := NewScreen(WithSize(80, 3), WithAltScreenPolicy(test.policy))
+			if err != nil {
+				t.Fatalf("NewScreen() error = %v", err)
+			}
+			s.Write([]byte("$ top\n\x1b[?1049h\x1b[H\x1b[1mload average: 0.1\x1b[0m\r\nPID USER\x1b[?1049l$ done"))
+			if diff := cmp.Diff(s.AsPlainText(), test.want); diff != "" {
+				t.Errorf("AsPlainText() diff (-got +want):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestAltScreenDoesNotScrollOut(t *testing.T) {
+	s, err := NewScreen(WithMaxSize(0, 3), WithAltScreenPolicy(AltScreenSnapshot))
+	if err != nil {
+		t.Fatalf("NewScreen() error = %v", err)
+	}
+	var got []string
+	s.ScrollOutFunc = func(line string) { got = append(got, line) }
+
+	s.Write([]byte("a\nb\n\x1b[?1049h\x1b[H1\n2\n3\n4\n5\x1b[?1049lc\n"))
+
+	// The snapshot of the alternate screen goes into the main screen, and can
+	// then scroll out like any other lines.
+	want := []string{"a\n", "b\n", "3\n"}
+	if diff := cmp.Diff(got, want); diff != "" {
+		t.Errorf("scrolled out lines diff (-got +want):\n%s", diff)
+	}
+	if got, want := s.LinesScrolledOut, 3; got != want {
+		t.Errorf("s.LinesScrolledOut = %d, want %d", got, want)
+	}
+	if diff := cmp.Diff(s.AsHTML(), "4\n5\nc"); diff != "" {
+		t.Errorf("AsHTML() diff (-got +want):\n%s", diff)
+	}
+}
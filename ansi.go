@@ -0,0 +1,246 @@
+package terminal
+
+import (
+	"strconv"
+	"strings"
+)
+
+// AsANSI returns the contents of the current screen buffer as normalised
+// ANSI: the final text of each line, after cursor movement, overwrites and
+// clears have been resolved, using only SGR sequences for styles, OSC 8 for
+// hyperlinks, and newlines. Each line ends with its styles reset, so lines
+// can be read independently.
+func (s *Screen) AsANSI() string {
+	return linesToANSI(s.contents(), s.htmlOptions.URLPolicy)
+}
+
+// linesToANSI renders screen lines as ANSI, joining wrapped lines together.
+// Links are checked against the URL policy (which can be nil).
+func linesToANSI(screen []screenLine, p *URLPolicy) string {
+	var sb strings.Builder
+	for _, parts := range logicalLines(screen) {
+		sb.WriteString(lineToANSI(parts, p))
+	}
+	return sb.String()
+}
+
+// lineToANSI joins parts of a line together and renders them as ANSI, with
+// the minimal SGR sequences needed to change from the style of one node to
+// the next. The output string will have a terminating \n.
+// Elements are rendered as text: links (and images with a URL) become OSC 8
+// hyperlinks, images become "[image: alt]", and the alternate screen is
+// rendered as its lines.
+func lineToANSI(parts []screenLine, p *URLPolicy) string {
+	var buf strings.Builder
+
+	// The style and link that are currently in effect.
+	var current style
+	var link string
+
+	setStyle := func(s style) {
+		if codes := sgrTransition(current, s); codes != "" {
+			buf.WriteString("\x1b[" + codes + "m")
+		}
+		current = s
+	}
+	setLink := func(url string) {
+		if url == link {
+			return
+		}
+		if link != "" {
+			buf.WriteString("\x1b]8;;\x1b\\")
+		}
+		if url != "" {
+			buf.WriteString("\x1b]8;;" + stripControls(url) + "\x1b\\")
+		}
+		link = url
+	}
+
+	// Trailing unstyled whitespace is dropped, so unstyled spaces are held
+	// back until something else is written.
+	spaces := 0
+	flushSpaces := func() {
+		buf.WriteString(strings.Repeat(" ", spaces))
+		spaces = 0
+	}
+
+	for _, l := range parts {
+		for x, n := range l.nodes {
+			if n.style.wideTail() && !n.style.conceal() {
+				// The first column of the wide character was already written.
+				// (Concealed text is replaced with spaces, one per column.)
+				continue
+			}
+
+			var url string
+			if n.style.hyperlink() {
+				url = p.apply(l.hyperlinks[x], URLLink)
+			}
+			if n.blob == ' ' && n.style.isPlain() && url == "" && l.combining[x] == "" {
+				setStyle(0)
+				setLink("")
+				spaces++
+				continue
+			}
+			flushSpaces()
+
+			if n.style.element() {
+				// Elements bring their own links, which must not be nested.
+				setLink("")
+				elem := l.elements[n.blob]
+				if elem.elementType == elementAltScreen {
+					setStyle(0)
+					buf.WriteString(strings.TrimSuffix(linesToANSI(elem.lines, p), "\n"))
+					continue
+				}
+				setStyle(n.style)
+				text, url := elem.asANSI()
+				if url != "" {
+					url = p.apply(url, elem.urlKind())
+				}
+				setLink(url)
+				buf.WriteString(stripControls(text))
+				setLink("")
+				continue
+			}
+
+			setStyle(n.style)
+			setLink(url)
+			if n.style.conceal() {
+				buf.WriteByte(' ')
+				continue
+			}
+			// Control characters (e.g. a C1 CSI that the parser didn't treat
+			// as one) would start escape sequences of their own.
+			if !isControl(n.blob) {
+				buf.WriteRune(n.blob)
+			}
+			buf.WriteString(stripControls(l.combining[x]))
+		}
+	}
+
+	// Leave everything reset for the next line.
+	setLink("")
+	setStyle(0)
+	buf.WriteByte('\n')
+	return buf.String()
+}
+
+// asANSI returns the text to display for the element, and the URL to link it
+// to (if any). The alternate screen is handled by lineToANSI.
+func (i *element) asANSI() (text, url string) {
+	switch i.elementType {
+	case elementLink:
+		if i.content == "" {
+			return i.url, i.url
+		}
+		return i.content, i.url
+
+	case elementImage:
+		alt := i.alt
+		if alt == "" {
+			alt = i.url
+		}
+		return "[image: " + alt + "]", i.url
+
+	case elementITermImage:
+		alt := i.alt
+		if alt == "" {
+			alt = i.url
+		}
+		return "[image: " + alt + "]", ""
+	}
+	return "", ""
+}
+
+// stripControls removes control characters from s, so that it can't end (or
+// start) an escape sequence.
+func stripControls(s string) string {
+	return strings.Map(func(r rune) rune {
+		if isControl(r) {
+			return -1
+		}
+		return r
+	}, s)
+}
+
+// isControl reports if r is a C0 or C1 control character, or DEL.
+func isControl(r rune) bool {
+	return r < 0x20 || (r >= 0x7f && r < 0xa0)
+}
+
+// sgrTransition returns the SGR parameters that change the style from one to
+// the other (without the CSI and final "m"), or "" if no change is needed.
+// Either the differences are set, or everything is reset and then set,
+// whichever is shorter.
+func sgrTransition(from, to style) string {
+	from &= styleComparisonMask
+	to &= styleComparisonMask
+	if from == to {
+		return ""
+	}
+	reset := append([]string{"0"}, sgrDiff(0, to)...)
+	diff := sgrDiff(from, to)
+	if len(reset) == 1 || len(strings.Join(reset, ";")) <= len(strings.Join(diff, ";")) {
+		return strings.Join(reset, ";")
+	}
+	return strings.Join(diff, ";")
+}
+
+// sgrDiff returns the SGR parameters that change the style from one to the
+// other, without resetting everything.
+func sgrDiff(from, to style) []string {
+	var codes []string
+
+	// Bold and faint are both turned off by the same code.
+	if (from.bold() && !to.bold()) || (from.faint() && !to.faint()) {
+		codes = append(codes, "22")
+		from.setBold(false)
+		from.setFaint(false)
+	}
+
+	attrs := []struct {
+		from, to bool
+		on, off  string
+	}{
+		{from.bold(), to.bold(), "1", ""},
+		{from.faint(), to.faint(), "2", ""},
+		{from.italic(), to.italic(), "3", "23"},
+		{from.underline(), to.underline(), "4", "24"},
+		{from.blink(), to.blink(), "5", "25"},
+		{from.reverse(), to.reverse(), "7", "27"},
+		{from.conceal(), to.conceal(), "8", "28"},
+		{from.strike(), to.strike(), "9", "29"},
+	}
+	for _, a := range attrs {
+		switch {
+		case a.to && !a.from:
+			codes = append(codes, a.on)
+		case a.from && !a.to:
+			codes = append(codes, a.off)
+		}
+	}
+
+	if from.fgColorType() != to.fgColorType() || from.fgColor() != to.fgColor() {
+		codes = append(codes, sgrColor(to.fgColorType(), to.fgColor(), "38", "39"))
+	}
+	if from.bgColorType() != to.bgColorType() || from.bgColor() != to.bgColor() {
+		codes = append(codes, sgrColor(to.bgColorType(), to.bgColor(), "48", "49"))
+	}
+	return codes
+}
+
+// sgrColor returns the SGR parameters for a colour, using the extended
+// colour code (38 or 48) for 8-bit and 24-bit colours, or the default code
+// (39 or 49) if there is no colour.
+func sgrColor(colorType uint8, c uint32, extended, def string) string {
+	switch colorType {
+	case colorSGR:
+		return strconv.Itoa(int(c))
+	case color8Bit:
+		return extended + ";5;" + strconv.Itoa(int(c))
+	case color24Bit:
+		return extended + ";2;" + strconv.Itoa(int(c>>16&0xff)) + ";" + strconv.Itoa(int(c>>8&0xff)) + ";" + strconv.Itoa(int(c&0xff))
+	}
+	return def
+}
@@ -0,0 +1,167 @@
+package terminal
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestScreenAsANSI(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{
+			name:  "plain text",
+			input: "hello\nworld   ",
+			want:  "hello\nworld\n",
+		},
+		{
+			name:  "overwrites and clears are resolved",
+			input: "progress  10%\rprogress 100%\nspinner |\x08/\x08-\x08\\\x08\x1b[K\ndone",
+			want:  "progress 100%\nspinner\ndone\n",
+		},
+		{
+			name:  "styles are reset at the end of each line",
+			input: "\x1b[31mred\nstill red\x1b[0m",
+			want:  "\x1b[31mred\x1b[0m\n\x1b[31mstill red\x1b[0m\n",
+		},
+		{
+			name:  "only changes are emitted",
+			input: "\x1b[1ma\x1b[31mb\x1b[1;31mc\x1b[4md\x1b[24me\x1b[39mf",
+			want:  "\x1b[1ma\x1b[31mbc\x1b[4md\x1b[24me\x1b[39mf\x1b[0m\n",
+		},
+		{
+			name:  "reset when it is shorter",
+			input: "\x1b[1;3;4;31mabc\x1b[0;32mdef",
+			want:  "\x1b[1;3;4;31mabc\x1b[0;32mdef\x1b[0m\n",
+		},
+		{
+			name:  "bold to faint",
+			input: "\x1b[1;3ma\x1b[22;2mb",
+			want:  "\x1b[1;3ma\x1b[22;2mb\x1b[0m\n",
+		},
+		{
+			name:  "8-bit and 24-bit colours",
+			input: "\x1b[38;5;208ma\x1b[48;2;1;2;3mb\x1b[49mc",
+			want:  "\x1b[38;5;208ma\x1b[48;2;1;2;3mb\x1b[49mc\x1b[0m\n",
+		},
+		{
+			name:  "styled trailing spaces are kept",
+			input: "a\x1b[41m  \x1b[0m  ",
+			want:  "a\x1b[41m  \x1b[0m\n",
+		},
+		{
+			name:  "hyperlinks",
+			input: "a \x1b]8;;http://example.com\x1b\\link\x1b]8;;\x1b\\ \x1b]8;;javascript:alert(1)\x1b\\bad\x1b]8;;\x1b\\",
+			want:  "a \x1b]8;;http://example.com\x1b\\link\x1b]8;;\x1b\\ \x1b]8;;#\x1b\\bad\x1b]8;;\x1b\\\n",
+		},
+		{
+			name:  "hyperlinks are closed at the end of the line",
+			input: "\x1b]8;;http://example.com\x1b\\a\nb\x1b]8;;\x1b\\",
+			want:  "\x1b]8;;http://example.com\x1b\\a\x1b]8;;\x1b\\\n\x1b]8;;http://example.com\x1b\\b\x1b]8;;\x1b\\\n",
+		},
+		{
+			name:  "concealed text is replaced with spaces",
+			input: "\x1b[8msecret\x1b[0m!",
+			want:  "\x1b[8m      \x1b[0m!\n",
+		},
+		{
+			name:  "wide and combining characters",
+			input: "日本 é",
+			want:  "日本 é\n",
+		},
+		{
+			name:  "link element",
+			input: "see \x1b]1339;url=http://example.com;content=example\x07!",
+			want:  "see \x1b]8;;http://example.com\x1b\\example\x1b]8;;\x1b\\!\n",
+		},
+		{
+			name:  "image element",
+			input: "\x1b]1338;url=http://example.com/a.png;alt=A picture\x07",
+			want:  "\x1b]8;;http://example.com/a.png\x1b\\[image: A picture]\x1b]8;;\x1b\\\n",
+		},
+		{
+			name:  "inline image element",
+			input: "\x1b]1337;File=name=" + base64Encode("a.gif") + ";inline=1:AA==\x07",
+			want:  "[image: a.gif]\n",
+		},
+		{
+			name:  "control characters are removed from element text",
+			input: "\x1b]1339;url=http://example.com;content=a\x1bb\x07",
+			want:  "\x1b]8;;http://example.com\x1b\\ab\x1b]8;;\x1b\\\n",
+		},
+		{
+			name:  "C1 control characters are removed from text",
+			input: "a\u009b31mb\u009d0;title\u0007c\u0301\u009b",
+			want:  "a31mb0;titlec\u0301\n",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			s, err := NewScreen()
+			if err != nil {
+				t.Fatalf("NewScreen() = %v", err)
+			}
+			s.Write([]byte(test.input))
+			if diff := cmp.Diff(s.AsANSI(), test.want); diff != "" {
+				t.Errorf("s.AsANSI() diff (-got +want):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestScreenANSIScrollOut(t *testing.T) {
+	s, err := NewScreen(WithMaxSize(0, 2), WithFormat(FormatANSI))
+	if err != nil {
+		t.Fatalf("NewScreen() = %v", err)
+	}
+	var scrolledOut strings.Builder
+	s.ScrollOutFunc = func(line string) { scrolledOut.WriteString(line) }
+	s.Write([]byte("one\n\x1b[32mtwo\nthree\x1b[0m\nfour"))
+
+	got := scrolledOut.String() + s.AsFormat(FormatANSI)
+	want := "one\n\x1b[32mtwo\x1b[0m\n\x1b[32mthree\x1b[0m\nfour\n"
+	if diff := cmp.Diff(got, want); diff != "" {
+		t.Errorf("scrolled out + s.AsFormat(FormatANSI) diff (-got +want):\n%s", diff)
+	}
+}
+
+func TestANSIRoundTripAgainstFixtures(t *testing.T) {
+	// Rendering the normalised ANSI should give the same HTML as rendering the
+	// original, except for timestamps (bk APC sequences aren't re-emitted),
+	// and trailing blank lines.
+	timeTag := regexp.MustCompile(`<time datetime="[^"]*">[^<]*</time>`)
+	normalise := func(html string) string {
+		lines := strings.Split(timeTag.ReplaceAllString(html, ""), "\n")
+		for i, l := range lines {
+			if l == "&nbsp;" {
+				lines[i] = ""
+			}
+		}
+		return strings.TrimRight(strings.Join(lines, "\n"), "\n")
+	}
+
+	for _, base := range TestFiles {
+		t.Run(fmt.Sprintf("for fixture %q", base), func(t *testing.T) {
+			raw := loadFixture(t, base, "raw")
+			want := normalise(string(loadFixture(t, base, "rendered")))
+
+			s, err := NewScreen()
+			if err != nil {
+				t.Fatalf("NewScreen() = %v", err)
+			}
+			s.Write(raw)
+			got := normalise(Render([]byte(s.AsANSI())))
+
+			if diff := cmp.Diff(got, want); diff != "" {
+				t.Errorf("Render(s.AsANSI()) diff (-got +want):\n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,327 @@
+package terminal
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Event types in asciicast v2 recordings.
+const (
+	CastOutput = "o" // data written to the terminal
+	CastInput  = "i" // data typed by the user
+	CastResize = "r" // the terminal was resized to "COLSxROWS"
+	CastMarker = "m" // a marker (breakpoint) with a label
+)
+
+// CastHeader is the header (first line) of an asciicast v2 recording.
+// See https://docs.asciinema.org/manual/asciicast/v2/
+type CastHeader struct {
+	Version       int               `json:"version"`
+	Width         int               `json:"width"`
+	Height        int               `json:"height"`
+	Timestamp     int64             `json:"timestamp,omitempty"`
+	Duration      float64           `json:"duration,omitempty"`
+	IdleTimeLimit float64           `json:"idle_time_limit,omitempty"`
+	Command       string            `json:"command,omitempty"`
+	Title         string            `json:"title,omitempty"`
+	Env           map[string]string `json:"env,omitempty"`
+}
+
+// CastEvent is an event in an asciicast v2 recording. In the file, events
+// are arrays of the form [time, type, data].
+type CastEvent struct {
+	// Time is the number of seconds since the start of the recording.
+	Time float64
+
+	// Type is one of CastOutput, CastInput, CastResize or CastMarker.
+	Type string
+
+	Data string
+}
+
+// UnmarshalJSON decodes an event from [time, type, data].
+func (e *CastEvent) UnmarshalJSON(b []byte) error {
+	var parts []json.RawMessage
+	if err := json.Unmarshal(b, &parts); err != nil {
+		return err
+	}
+	if len(parts) != 3 {
+		return fmt.Errorf("event has %d parts, want 3", len(parts))
+	}
+	if err := json.Unmarshal(parts[0], &e.Time); err != nil {
+		return fmt.Errorf("event time: %w", err)
+	}
+	if err := json.Unmarshal(parts[1], &e.Type); err != nil {
+		return fmt.Errorf("event type: %w", err)
+	}
+	if err := json.Unmarshal(parts[2], &e.Data); err != nil {
+		return fmt.Errorf("event data: %w", err)
+	}
+	return nil
+}
+
+// MarshalJSON encodes the event as [time, type, data].
+func (e CastEvent) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode([]any{e.Time, e.Type, e.Data}); err != nil {
+		return nil, err
+	}
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n")), nil
+}
+
+// CastReader reads asciicast v2 recordings.
+type CastReader struct {
+	// Header is the header of the recording, read by NewCastReader.
+	Header CastHeader
+
+	dec *json.Decoder
+}
+
+// NewCastReader reads the header of an asciicast v2 recording, and returns a
+// reader for the events that follow.
+func NewCastReader(r io.Reader) (*CastReader, error) {
+	cr := &CastReader{dec: json.NewDecoder(r)}
+	if err := cr.dec.Decode(&cr.Header); err != nil {
+		return nil, fmt.Errorf("reading asciicast header: %w", err)
+	}
+	if cr.Header.Version != 2 {
+		return nil, fmt.Errorf("unsupported asciicast version %d", cr.Header.Version)
+	}
+	return cr, nil
+}
+
+// Next returns the next event in the recording, or io.EOF if there are no
+// more events.
+func (cr *CastReader) Next() (CastEvent, error) {
+	var e CastEvent
+	if err := cr.dec.Decode(&e); err != nil {
+		if errors.Is(err, io.EOF) {
+			return e, io.EOF
+		}
+		return e, fmt.Errorf("reading asciicast event: %w", err)
+	}
+	return e, nil
+}
+
+// WriteCast plays an asciicast v2 recording into the screen, as fast as
+// possible. The window size is set from the header (as with WithPTYSize),
+// output events are written to the screen, and resize events resize it.
+// Other events are ignored.
+func (s *Screen) WriteCast(r io.Reader) error {
+	cr, err := NewCastReader(r)
+	if err != nil {
+		return err
+	}
+	if err := s.setPTYSize(cr.Header.Width, cr.Header.Height); err != nil {
+		return fmt.Errorf("asciicast header size: %w", err)
+	}
+	for {
+		e, err := cr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		switch e.Type {
+		case CastOutput:
+			s.Write([]byte(e.Data))
+
+		case CastResize:
+			var cols, lines int
+			if _, err := fmt.Sscanf(e.Data, "%dx%d", &cols, &lines); err != nil {
+				return fmt.Errorf("asciicast resize event %q: %w", e.Data, err)
+			}
+			if err := s.SetSize(cols, lines); err != nil {
+				return fmt.Errorf("asciicast resize event %q: %w", e.Data, err)
+			}
+		}
+	}
+}
+
+// CastWriter writes asciicast v2 recordings.
+type CastWriter struct {
+	enc *json.Encoder
+}
+
+// NewCastWriter writes the header of an asciicast v2 recording, and returns a
+// writer for the events that follow. If the header's Version is 0, it is set
+// to 2.
+func NewCastWriter(w io.Writer, h CastHeader) (*CastWriter, error) {
+	if h.Version == 0 {
+		h.Version = 2
+	}
+	cw := &CastWriter{enc: json.NewEncoder(w)}
+	cw.enc.SetEscapeHTML(false)
+	if err := cw.enc.Encode(h); err != nil {
+		return nil, fmt.Errorf("writing asciicast header: %w", err)
+	}
+	return cw, nil
+}
+
+// WriteEvent writes an event to the recording.
+func (cw *CastWriter) WriteEvent(e CastEvent) error {
+	if err := cw.enc.Encode(e); err != nil {
+		return fmt.Errorf("writing asciicast event: %w", err)
+	}
+	return nil
+}
+
+// ExportCast converts a raw Buildkite job log into an asciicast v2 recording,
+// so it can be replayed with asciinema. The times of output events come from
+// the timestamps in bk APC sequences (t= and dt=), which are removed from the
+// output. The size in the header is the window size, as set by the options
+// or declared in the log (see WithPTYSize), and later changes to the declared
+// size become resize events.
+func ExportCast(dst io.Writer, src io.Reader, opts ...ScreenOption) error {
+	s, err := NewScreen(opts...)
+	if err != nil {
+		return err
+	}
+	x := &castExporter{dst: dst, screen: s}
+	rd := bufio.NewReader(src)
+	for {
+		chunk, err := rd.ReadBytes('\x1b')
+		if err == io.EOF {
+			x.output = append(x.output, chunk...)
+			if err := x.flush(); err != nil {
+				return err
+			}
+			return x.writeHeader()
+		}
+		if err != nil {
+			return err
+		}
+		x.output = append(x.output, chunk...)
+
+		// Is it an APC?
+		if next, err := rd.Peek(1); err != nil || next[0] != '_' {
+			continue
+		}
+		rd.ReadByte()
+		apc, terminator, err := readAPC(rd)
+		if err != nil && err != io.EOF {
+			return err
+		}
+		if terminator == "" || !strings.HasPrefix(apc, bkNamespace+";") {
+			// Not ours (or unterminated), so leave it in the output.
+			x.output = append(x.output, "_"+apc+terminator...)
+			continue
+		}
+		x.output = x.output[:len(x.output)-1] // the ESC
+		if err := x.buildkiteAPC(apc); err != nil {
+			return err
+		}
+	}
+}
+
+// readAPC reads the contents of an APC, up to and including its terminator
+// (BEL or ESC \). The terminator is returned separately, and is "" if the
+// input ended first.
+func readAPC(rd *bufio.Reader) (apc, terminator string, err error) {
+	var sb strings.Builder
+	for {
+		b, err := rd.ReadByte()
+		if err != nil {
+			return sb.String(), "", err
+		}
+		switch b {
+		case '\x07':
+			return sb.String(), "\x07", nil
+		case '\x1b':
+			if next, err := rd.Peek(1); err == nil && next[0] == '\\' {
+				rd.ReadByte()
+				return sb.String(), "\x1b\\", nil
+			}
+		}
+		sb.WriteByte(b)
+	}
+}
+
+// castExporter holds the state of ExportCast.
+type castExporter struct {
+	dst    io.Writer
+	screen *Screen
+	writer *CastWriter
+
+	// Output that hasn't been written as an event yet.
+	output []byte
+
+	// The first timestamp in the log (in milliseconds), which is time 0 in the
+	// recording, and the time of the last event (in seconds).
+	start    int64
+	lastTime float64
+
+	// The window size in the header or last resize event.
+	cols, lines int
+}
+
+// buildkiteAPC writes the output so far as an event, then processes the APC.
+func (x *castExporter) buildkiteAPC(apc string) error {
+	if err := x.flush(); err != nil {
+		return err
+	}
+	p := &x.screen.parser
+	if _, err := p.parseBuildkiteAPC(apc); err != nil {
+		// The same message is rendered in HTML.
+		x.output = append(x.output, "*** Error parsing Buildkite APC ANSI escape sequence: "+err.Error()...)
+		return nil
+	}
+	if x.start == 0 {
+		x.start = p.lastTimestamp
+	}
+	if x.writer != nil && (x.screen.cols != x.cols || x.screen.lines != x.lines) {
+		x.cols, x.lines = x.screen.cols, x.screen.lines
+		return x.event(CastResize, fmt.Sprintf("%dx%d", x.cols, x.lines))
+	}
+	return nil
+}
+
+// flush writes the output so far as an output event, if there is any.
+func (x *castExporter) flush() error {
+	if len(x.output) == 0 {
+		return nil
+	}
+	if err := x.writeHeader(); err != nil {
+		return err
+	}
+	err := x.event(CastOutput, string(x.output))
+	x.output = x.output[:0]
+	return err
+}
+
+// writeHeader writes the header, if it hasn't been written yet. This happens
+// just before the first event, so that it has the size declared at the start
+// of the log.
+func (x *castExporter) writeHeader() error {
+	if x.writer != nil {
+		return nil
+	}
+	x.cols, x.lines = x.screen.cols, x.screen.lines
+	w, err := NewCastWriter(x.dst, CastHeader{
+		Width:     x.cols,
+		Height:    x.lines,
+		Timestamp: x.start / 1000,
+	})
+	if err != nil {
+		return err
+	}
+	x.writer = w
+	return nil
+}
+
+// event writes an event at the time of the last timestamp.
+func (x *castExporter) event(typ, data string) error {
+	if x.start != 0 {
+		// Events can't go back in time.
+		x.lastTime = max(x.lastTime, float64(x.screen.parser.lastTimestamp-x.start)/1000)
+	}
+	return x.writer.WriteEvent(CastEvent{Time: x.lastTime, Type: typ, Data: data})
+}
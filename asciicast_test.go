@@ -0,0 +1,273 @@
+package terminal
+
+import (
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestCastReader(t *testing.T) {
+	cast := `{"version": 2, "width": 80, "height": 24, "timestamp": 1504467315, "idle_time_limit": 2.5, "env": {"TERM": "xterm-256color"}}
+[0.248848, "o", "\u001b[1;31mHello \u001b[32mWorld!\u001b[0m\n"]
+[1.001376, "i", "q"]
+[1.5, "r", "100x40"]
+[2.5, "m", "chapter 2"]
+`
+	cr, err := NewCastReader(strings.NewReader(cast))
+	if err != nil {
+		t.Fatalf("NewCastReader() error = %v", err)
+	}
+	wantHeader := CastHeader{
+		Version:       2,
+		Width:         80,
+		Height:        24,
+		Timestamp:     1504467315,
+		IdleTimeLimit: 2.5,
+		Env:           map[string]string{"TERM": "xterm-256color"},
+	}
+	if diff := cmp.Diff(cr.Header, wantHeader); diff != "" {
+		t.Errorf("cr.Header diff (-got +want):\n%s", diff)
+	}
+
+	var got []CastEvent
+	for {
+		e, err := cr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("cr.Next() error = %v", err)
+		}
+		got = append(got, e)
+	}
+	want := []CastEvent{
+		{Time: 0.248848, Type: CastOutput, Data: "\x1b[1;31mHello \x1b[32mWorld!\x1b[0m\n"},
+		{Time: 1.001376, Type: CastInput, Data: "q"},
+		{Time: 1.5, Type: CastResize, Data: "100x40"},
+		{Time: 2.5, Type: CastMarker, Data: "chapter 2"},
+	}
+	if diff := cmp.Diff(got, want); diff != "" {
+		t.Errorf("events diff (-got +want):\n%s", diff)
+	}
+}
+
+func TestCastReaderErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		cast string
+	}{
+		{
+			name: "not JSON",
+			cast: "hello",
+		},
+		{
+			name: "version 1",
+			cast: `{"version": 1, "width": 80, "height": 24, "stdout": []}`,
+		},
+		{
+			name: "event with too few parts",
+			cast: `{"version": 2, "width": 80, "height": 24}` + "\n" + `[0.1, "o"]`,
+		},
+		{
+			name: "event with a non-numeric time",
+			cast: `{"version": 2, "width": 80, "height": 24}` + "\n" + `["0.1", "o", "hi"]`,
+		},
+		{
+			name: "bad resize",
+			cast: `{"version": 2, "width": 80, "height": 24}` + "\n" + `[0.1, "r", "big"]`,
+		},
+		{
+			name: "size too large",
+			cast: `{"version": 2, "width": 8000, "height": 24}`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			s, err := NewScreen(WithMaxSize(400, 300))
+			if err != nil {
+				t.Fatalf("NewScreen() = %v", err)
+			}
+			if err := s.WriteCast(strings.NewReader(test.cast)); err == nil {
+				t.Errorf("s.WriteCast(%q) error = nil, want error", test.cast)
+			}
+		})
+	}
+}
+
+func TestScreenWriteCast(t *testing.T) {
+	cast := `{"version": 2, "width": 10, "height": 3}
+[0.1, "o", "$ ls\r\n"]
+[0.2, "i", "should not appear"]
+[0.3, "o", "one two three four"]
+[0.4, "r", "20x3"]
+[0.5, "o", "\r\n\u001b[32m$\u001b[0m "]
+[0.6, "o", "\u001b[2;4H!"]
+`
+	s, err := NewScreen()
+	if err != nil {
+		t.Fatalf("NewScreen() = %v", err)
+	}
+	if err := s.WriteCast(strings.NewReader(cast)); err != nil {
+		t.Fatalf("s.WriteCast() error = %v", err)
+	}
+
+	// The resize reflows "one two three four" onto one line, and the header
+	// size makes absolute positions exact.
+	want := "$ ls\none!two three four\n<span class=\"term-fg32\">$</span>"
+	if diff := cmp.Diff(s.AsHTML(), want); diff != "" {
+		t.Errorf("s.AsHTML() diff (-got +want):\n%s", diff)
+	}
+}
+
+func TestCastWriter(t *testing.T) {
+	var buf strings.Builder
+	cw, err := NewCastWriter(&buf, CastHeader{Width: 80, Height: 24, Title: "<test>"})
+	if err != nil {
+		t.Fatalf("NewCastWriter() error = %v", err)
+	}
+	events := []CastEvent{
+		{Time: 0, Type: CastOutput, Data: "\x1b[31m<hello>\x1b[0m\r\n"},
+		{Time: 1.5, Type: CastResize, Data: "100x40"},
+	}
+	for _, e := range events {
+		if err := cw.WriteEvent(e); err != nil {
+			t.Fatalf("cw.WriteEvent(%v) error = %v", e, err)
+		}
+	}
+
+	want := `{"version":2,"width":80,"height":24,"title":"<test>"}
+[0,"o","\u001b[31m<hello>\u001b[0m\r\n"]
+[1.5,"r","100x40"]
+`
+	if diff := cmp.Diff(buf.String(), want); diff != "" {
+		t.Errorf("written cast diff (-got +want):\n%s", diff)
+	}
+
+	// It should read back the same.
+	cr, err := NewCastReader(strings.NewReader(buf.String()))
+	if err != nil {
+		t.Fatalf("NewCastReader() error = %v", err)
+	}
+	for _, want := range events {
+		got, err := cr.Next()
+		if err != nil {
+			t.Fatalf("cr.Next() error = %v", err)
+		}
+		if diff := cmp.Diff(got, want); diff != "" {
+			t.Errorf("cr.Next() diff (-got +want):\n%s", diff)
+		}
+	}
+}
+
+func TestExportCast(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		opts  []ScreenOption
+		want  string
+	}{
+		{
+			name:  "no timestamps",
+			input: "hello\r\nworld",
+			want: `{"version":2,"width":160,"height":100}
+[0,"o","hello\r\nworld"]
+`,
+		},
+		{
+			name:  "timestamps",
+			input: "\x1b_bk;t=1700000000000\x07one\r\n\x1b_bk;t=1700000001500\x07two\r\n\x1b_bk;dt=250\x07\x1b[32mthree\x1b[0m\r\n",
+			want: `{"version":2,"width":160,"height":100,"timestamp":1700000000}
+[0,"o","one\r\n"]
+[1.5,"o","two\r\n"]
+[1.75,"o","\u001b[32mthree\u001b[0m\r\n"]
+`,
+		},
+		{
+			name:  "time doesn't go backwards",
+			input: "\x1b_bk;t=2000\x07a\x1b_bk;t=1000\x07b",
+			want: `{"version":2,"width":160,"height":100,"timestamp":2}
+[0,"o","a"]
+[0,"o","b"]
+`,
+		},
+		{
+			name:  "size from the options",
+			input: "hi",
+			opts:  []ScreenOption{WithSize(80, 24)},
+			want: `{"version":2,"width":80,"height":24}
+[0,"o","hi"]
+`,
+		},
+		{
+			name:  "declared size",
+			input: "\x1b_bk;t=1000;cols=100;lines=30\x07hi\r\n\x1b_bk;t=3000;cols=120\x1b\\wider\r\n",
+			want: `{"version":2,"width":100,"height":30,"timestamp":1}
+[0,"o","hi\r\n"]
+[2,"r","120x30"]
+[2,"o","wider\r\n"]
+`,
+		},
+		{
+			name:  "other escapes are kept",
+			input: "\x1b_bk;t=1000\x07\x1b]8;;http://example.com\x1b\\link\x1b]8;;\x1b\\ \x1b_other\x07\x1b",
+			want: `{"version":2,"width":160,"height":100,"timestamp":1}
+[0,"o","\u001b]8;;http://example.com\u001b\\link\u001b]8;;\u001b\\ \u001b_other\u0007\u001b"]
+`,
+		},
+		{
+			name:  "bad APC",
+			input: "\x1b_bk;t=soon\x07hi",
+			want: `{"version":2,"width":160,"height":100}
+[0,"o","*** Error parsing Buildkite APC ANSI escape sequence: t key has non-integer value \"soon\": strconv.ParseInt: parsing \"soon\": invalid syntaxhi"]
+`,
+		},
+		{
+			name:  "empty",
+			input: "",
+			want: `{"version":2,"width":160,"height":100}
+`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var buf strings.Builder
+			if err := ExportCast(&buf, strings.NewReader(test.input), test.opts...); err != nil {
+				t.Fatalf("ExportCast() error = %v", err)
+			}
+			if diff := cmp.Diff(buf.String(), test.want); diff != "" {
+				t.Errorf("ExportCast() diff (-got +want):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestExportCastReplays(t *testing.T) {
+	// Replaying the exported cast should render the same as the original log,
+	// apart from the timestamps.
+	raw := loadFixture(t, "buildah-build.sh", "raw")
+	var cast strings.Builder
+	if err := ExportCast(&cast, strings.NewReader(string(raw))); err != nil {
+		t.Fatalf("ExportCast() error = %v", err)
+	}
+
+	s, err := NewScreen()
+	if err != nil {
+		t.Fatalf("NewScreen() = %v", err)
+	}
+	if err := s.WriteCast(strings.NewReader(cast.String())); err != nil {
+		t.Fatalf("s.WriteCast() error = %v", err)
+	}
+
+	want, err := NewScreen()
+	if err != nil {
+		t.Fatalf("NewScreen() = %v", err)
+	}
+	want.Write(raw)
+	if diff := cmp.Diff(s.AsPlainText(), want.AsPlainText()); diff != "" {
+		t.Errorf("replayed s.AsPlainText() diff (-got +want):\n%s", diff)
+	}
+}
@@ -22,6 +22,9 @@ func (p *parser) parseBuildkiteAPC(sequence string) (map[string]string, error) {
 
 	data := map[string]string{}
 
+	// The PTY size, if declared. 0 means not declared.
+	var cols, lines int
+
 	for _, token := range tokens {
 		tokenParts := strings.SplitN(token, "=", 2)
 		if len(tokenParts) != 2 {
@@ -47,10 +50,45 @@ func (p *parser) parseBuildkiteAPC(sequence string) (map[string]string, error) {
 			p.lastTimestamp += dt
 			data["t"] = strconv.FormatInt(p.lastTimestamp, 10)
 
+		case "cols", "lines":
+			n, err := strconv.Atoi(val)
+			if err != nil || n <= 0 {
+				return nil, fmt.Errorf("%s key has invalid value %q", key, val)
+			}
+			if key == "cols" {
+				cols = n
+			} else {
+				lines = n
+			}
+
 		default:
 			data[key] = val
 		}
 	}
 
+	if cols != 0 || lines != 0 {
+		// The PTY size applies to the whole screen, not to this line.
+		if cols == 0 {
+			cols = p.screen.cols
+		}
+		if lines == 0 {
+			lines = p.screen.lines
+		}
+		// Without a maximum size, limit it to the defaults rather than
+		// trusting the stream. (Over the maximum size is an error.)
+		if p.screen.maxColumns <= 0 {
+			cols = min(cols, defaultMaxStreamColumns)
+		}
+		if p.screen.maxLines <= 0 {
+			lines = min(lines, defaultMaxStreamLines)
+		}
+		if err := p.screen.setPTYSize(cols, lines); err != nil {
+			return nil, err
+		}
+		if len(data) == 0 {
+			return nil, nil
+		}
+	}
+
 	return data, nil
 }
@@ -58,3 +58,37 @@ func TestParseBuildkiteAPC(t *testing.T) {
 
 	}
 }
+
+func TestParseBuildkiteAPCSize(t *testing.T) {
+	s, err := NewScreen()
+	if err != nil {
+		t.Fatalf("NewScreen() error = %v", err)
+	}
+	p := &s.parser
+
+	got, err := p.parseBuildkiteAPC("bk;cols=80;lines=24")
+	if err != nil {
+		t.Fatalf("parseBuildkiteAPC error = %v", err)
+	}
+	if got != nil {
+		t.Errorf("parseBuildkiteAPC data = %v, want nil", got)
+	}
+	if diff := cmp.Diff([3]any{s.cols, s.lines, s.ptySizeKnown}, [3]any{80, 24, true}); diff != "" {
+		t.Errorf("screen size diff (-got +want):\n%s", diff)
+	}
+
+	got, err = p.parseBuildkiteAPC("bk;t=123;lines=30")
+	if err != nil {
+		t.Fatalf("parseBuildkiteAPC error = %v", err)
+	}
+	if diff := cmp.Diff(got, map[string]string{"t": "123"}); diff != "" {
+		t.Errorf("parsed buildkite APC data diff (-got +want):\n%s", diff)
+	}
+	if diff := cmp.Diff([2]int{s.cols, s.lines}, [2]int{80, 30}); diff != "" {
+		t.Errorf("screen size diff (-got +want):\n%s", diff)
+	}
+
+	if _, err := p.parseBuildkiteAPC("bk;cols=wide"); err == nil {
+		t.Error("parseBuildkiteAPC(bk;cols=wide) error = nil, want an error")
+	}
+}
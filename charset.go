@@ -0,0 +1,58 @@
+package terminal
+
+// charsetSpecialGraphics designates the DEC Special Graphics character set
+// (ESC ( 0 or ESC ) 0).
+const charsetSpecialGraphics = '0'
+
+// decSpecialGraphics maps characters to the DEC Special Graphics character
+// set, which is mostly used for drawing boxes and lines.
+var decSpecialGraphics = map[rune]rune{
+	'_': ' ', // blank
+	'`': '◆',
+	'a': '▒',
+	'b': '␉',
+	'c': '␌',
+	'd': '␍',
+	'e': '␊',
+	'f': '°',
+	'g': '±',
+	'h': '␤',
+	'i': '␋',
+	'j': '┘',
+	'k': '┐',
+	'l': '┌',
+	'm': '└',
+	'n': '┼',
+	'o': '⎺',
+	'p': '⎻',
+	'q': '─',
+	'r': '⎼',
+	's': '⎽',
+	't': '├',
+	'u': '┤',
+	'v': '┴',
+	'w': '┬',
+	'x': '│',
+	'y': '≤',
+	'z': '≥',
+	'{': 'π',
+	'|': '≠',
+	'}': '£',
+	'~': '·',
+}
+
+// translate maps a character through the character set currently in use
+// (G0, or G1 after a shift out).
+func (p *parser) translate(char rune) rune {
+	charset := p.charsets[0]
+	if p.shiftOut {
+		charset = p.charsets[1]
+	}
+	if charset != charsetSpecialGraphics {
+		return char
+	}
+	if r, ok := decSpecialGraphics[char]; ok {
+		return r
+	}
+	return char
+}
@@ -7,9 +7,11 @@ import (
 	"io"
 	"log"
 	"net/http"
+	"net/url"
 	"os"
 	"runtime"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/buildkite/terminal-to-html/v3"
@@ -27,6 +29,9 @@ WEBSERVICE USAGE:
   {{.Name}} --http :6060 &
   curl --data-binary "@input.raw" http://localhost:6060/terminal > out.html
 
+STYLESHEET USAGE:
+  {{.Name}} emit-css [--theme NAME] > terminal.css
+
 OPTIONS:
   {{range .Flags}}{{.}}
   {{end}}
@@ -54,8 +59,17 @@ const (
 `
 )
 
-func writePreviewStart(w io.Writer) error {
-	styleSheet, err := assets.TerminalCSS()
+// styleSheet returns the stylesheet for the theme, or the default stylesheet
+// if theme is nil.
+func styleSheet(theme *terminal.Theme) ([]byte, error) {
+	if theme != nil {
+		return []byte(theme.CSS()), nil
+	}
+	return assets.TerminalCSS()
+}
+
+func writePreviewStart(w io.Writer, theme *terminal.Theme) error {
+	styleSheet, err := styleSheet(theme)
 	if err != nil {
 		return err
 	}
@@ -76,7 +90,7 @@ func writePreviewEnd(w io.Writer) error {
 	return err
 }
 
-func webservice(listen string, preview bool, screen *terminal.Screen) {
+func webservice(listen string, preview bool, theme *terminal.Theme, format terminal.Format, cast bool, screen *terminal.Screen) {
 	http.HandleFunc("/terminal", func(w http.ResponseWriter, r *http.Request) {
 		// The main handler passes in an empty screen with an initial window
 		// size. Make a copy per request.
@@ -90,13 +104,13 @@ func webservice(listen string, preview bool, screen *terminal.Screen) {
 		// > Request.Body.
 		// However, it lets us provide Content-Length in all cases.
 		b := bytes.NewBuffer(nil)
-		if _, _, err := process(b, r.Body, preview, &screen); err != nil {
+		if _, _, err := process(b, r.Body, preview, theme, format, cast, &screen); err != nil {
 			log.Printf("error starting preview: %v", err)
 			w.WriteHeader(http.StatusInternalServerError)
 			fmt.Fprint(w, "Error creating preview.")
 		}
 
-		w.Header().Set("Content-Type", "text/html")
+		w.Header().Set("Content-Type", contentTypes[format])
 		w.Header().Set("Content-Length", strconv.Itoa(b.Len()))
 		if _, err := w.Write(b.Bytes()); err != nil {
 			log.Printf("error writing response: %v", err)
@@ -180,36 +194,117 @@ func (wc *writeCounter) Write(b []byte) (int, error) {
 
 func (wc *writeCounter) WriteString(s string) { wc.Write([]byte(s)) }
 
-// process streams the src through a terminal renderer to the dst. If preview is
-// true, the preview wrapper is added.
-func process(dst io.Writer, src io.Reader, preview bool, screen *terminal.Screen) (in, out int, err error) {
+type readCounter struct {
+	in      io.Reader
+	counter int
+}
+
+func (rc *readCounter) Read(b []byte) (int, error) {
+	n, err := rc.in.Read(b)
+	rc.counter += n
+	return n, err
+}
+
+// process streams the src through a terminal renderer to the dst, in the
+// format. If preview is true, the preview wrapper is added, with the
+// stylesheet for the theme. If cast is true, src is an asciicast v2
+// recording, and the final state of the screen is rendered.
+func process(dst io.Writer, src io.Reader, preview bool, theme *terminal.Theme, format terminal.Format, cast bool, screen *terminal.Screen) (in, out int, err error) {
 	// Wrap dst in writeCounter to count bytes written
 	wc := &writeCounter{out: dst}
 
 	if preview {
-		if err := writePreviewStart(wc); err != nil {
+		if err := writePreviewStart(wc, theme); err != nil {
 			return 0, wc.counter, fmt.Errorf("write start of preview: %w", err)
 		}
 	}
 
-	// Attach the scrollout callback before streaming input.
-	screen.ScrollOutFunc = wc.WriteString
+	// Attach the scrollout callback before streaming input. An image can't
+	// be streamed, so it is made from what remains in the buffer.
+	if format != terminal.FormatSVG {
+		screen.ScrollOutFunc = wc.WriteString
+	}
 
-	inBytes, err := io.Copy(screen, src)
-	if err != nil {
-		return int(inBytes), wc.counter, fmt.Errorf("read input into screen buffer: %w", err)
+	var inBytes int
+	if cast {
+		rc := &readCounter{in: src}
+		err := screen.WriteCast(rc)
+		inBytes = rc.counter
+		if err != nil {
+			return inBytes, wc.counter, fmt.Errorf("play asciicast into screen buffer: %w", err)
+		}
+	} else {
+		n, err := io.Copy(screen, src)
+		inBytes = int(n)
+		if err != nil {
+			return inBytes, wc.counter, fmt.Errorf("read input into screen buffer: %w", err)
+		}
 	}
 
 	// Write what remains in the screen buffer (everything that didn't scroll
 	// out of the top).
-	wc.WriteString(screen.AsHTML())
+	wc.WriteString(screen.AsFormat(format))
 
 	if preview {
 		if err := writePreviewEnd(wc); err != nil {
-			return int(inBytes), wc.counter, fmt.Errorf("write end of preview: %w", err)
+			return inBytes, wc.counter, fmt.Errorf("write end of preview: %w", err)
+		}
+	}
+	return inBytes, wc.counter, nil
+}
+
+// altScreenPolicies maps --alt-screen values to policies.
+var altScreenPolicies = map[string]terminal.AltScreenPolicy{
+	"discard":  terminal.AltScreenDiscard,
+	"snapshot": terminal.AltScreenSnapshot,
+	"inline":   terminal.AltScreenInline,
+}
+
+// formats maps --format values to output formats.
+var formats = map[string]terminal.Format{
+	"html": terminal.FormatHTML,
+	"json": terminal.FormatJSON,
+	"ansi": terminal.FormatANSI,
+	"svg":  terminal.FormatSVG,
+}
+
+// contentTypes are the HTTP Content-Types of the output formats.
+var contentTypes = map[terminal.Format]string{
+	terminal.FormatHTML: "text/html",
+	terminal.FormatJSON: "application/jsonl",
+	terminal.FormatANSI: "text/plain; charset=utf-8",
+	terminal.FormatSVG:  "image/svg+xml",
+}
+
+// themeNames returns a comma-separated list of the built-in theme names.
+func themeNames() string {
+	names := make([]string, 0, len(terminal.Themes))
+	for _, t := range terminal.Themes {
+		names = append(names, t.Name)
+	}
+	return strings.Join(names, ", ")
+}
+
+// urlPolicy returns the URL policy set by flags, or nil if there isn't one.
+func urlPolicy(c *cli.Context) *terminal.URLPolicy {
+	p := &terminal.URLPolicy{
+		AllowedSchemes: c.StringSlice("allowed-schemes"),
+		AllowedHosts:   c.StringSlice("allowed-hosts"),
+		Rel:            c.String("link-rel"),
+		Target:         c.String("link-target"),
+	}
+	if proxy := c.String("image-proxy"); proxy != "" {
+		p.Rewrite = func(u string, kind terminal.URLKind) string {
+			if kind != terminal.URLImage {
+				return u
+			}
+			return proxy + url.QueryEscape(u)
 		}
 	}
-	return int(inBytes), wc.counter, nil
+	if len(p.AllowedSchemes) == 0 && len(p.AllowedHosts) == 0 && p.Rel == "" && p.Target == "" && p.Rewrite == nil {
+		return nil
+	}
+	return p
 }
 
 func main() {
@@ -226,6 +321,11 @@ func main() {
 			Value: "",
 			Usage: "HTTP service mode (eg --http :6060), endpoint is /terminal",
 		},
+		&cli.StringFlag{
+			Name:  "format",
+			Value: "html",
+			Usage: "Output format. One of: html, json (JSON Lines, with one object per line of output containing its styled runs of text), ansi (the final text of each line with minimal ANSI styles, without cursor movement, overwrites or clears), svg (an image of the screen buffer, using --theme; see --buffer-max-lines)",
+		},
 		&cli.BoolFlag{
 			Name:  "preview",
 			Usage: "wrap output in HTML & CSS so it can be easily viewed directly in a browser",
@@ -254,19 +354,191 @@ func main() {
 			Value: 100,
 			Usage: "Sets the initial window height. Window size mainly affects cursor movement sequences",
 		},
+		&cli.StringFlag{
+			Name:  "pty-size",
+			Usage: "The actual size of the PTY that produced the input, as COLSxLINES (e.g. 160x100). When known, absolute cursor positioning is rendered exactly. Overrides --window-cols and --window-lines",
+		},
+		&cli.BoolFlag{
+			Name:  "inline-styles",
+			Usage: "Render colours and other styles as inline CSS instead of class names, so the output can be displayed without the stylesheet",
+		},
+		&cli.BoolFlag{
+			Name:  "sections",
+			Usage: "Render log groups (lines starting with ---, +++ or ~~~, and ::group::/::endgroup::) as collapsible blocks in HTML output",
+		},
+		&cli.BoolFlag{
+			Name:  "line-anchors",
+			Usage: "Give each line of HTML output an id (L1, L2, ...), so lines can be linked to as #L<n>",
+		},
+		&cli.BoolFlag{
+			Name:  "line-numbers",
+			Usage: "Add a gutter of line numbers to HTML output, each linking to its line. Implies --line-anchors",
+		},
+		&cli.BoolFlag{
+			Name:  "linkify",
+			Usage: "Turn bare http:// and https:// URLs in HTML output into links",
+		},
+		&cli.StringFlag{
+			Name:  "file-url",
+			Usage: "Turn file references like path/to/file.go:123:4 in HTML output into links, using this URL template. {path}, {line} and {col} are replaced, e.g. https://github.com/org/repo/blob/COMMIT/{path}#L{line}",
+		},
+		&cli.StringFlag{
+			Name:  "issue-url",
+			Usage: "Turn issue references like #123 in HTML output into links, using this URL template. {issue} is replaced with the number, e.g. https://github.com/org/repo/issues/{issue}",
+		},
+		&cli.IntFlag{
+			Name:  "max-image-bytes",
+			Usage: "The largest inline image (after decoding) to include. Larger images are replaced with a placeholder. 0 means no limit",
+		},
+		&cli.IntFlag{
+			Name:  "max-elements-per-line",
+			Usage: "The most images and links (from 1337, 1338 and 1339 sequences) in a line. 0 means no limit",
+		},
+		&cli.IntFlag{
+			Name:  "max-elements",
+			Usage: "The most images and links (from 1337, 1338 and 1339 sequences) in the whole output. 0 means no limit",
+		},
+		&cli.IntFlag{
+			Name:  "max-sequence-bytes",
+			Usage: "The longest OSC or APC escape sequence (such as an inline image) to process. Longer sequences are skipped, and replaced with a placeholder. 0 means no limit",
+		},
+		&cli.BoolFlag{
+			Name:  "safe",
+			Usage: "Render untrusted input as safe, well-formed HTML, with only span, a, img and time elements, and only http, https and mailto URLs (see the README)",
+		},
+		&cli.StringSliceFlag{
+			Name:  "allowed-schemes",
+			Usage: "Only allow links and images with these URL schemes (comma-separated, e.g. http,https). Others link to # or aren't shown. By default, any scheme except javascript: is allowed",
+		},
+		&cli.StringSliceFlag{
+			Name:  "allowed-hosts",
+			Usage: "Only allow links and images to these hosts (comma-separated). *.example.com allows subdomains of example.com",
+		},
+		&cli.StringFlag{
+			Name:  "link-rel",
+			Usage: "The rel attribute for links in HTML output, e.g. \"nofollow noopener\"",
+		},
+		&cli.StringFlag{
+			Name:  "link-target",
+			Usage: "The target attribute for links in HTML output, e.g. _blank",
+		},
+		&cli.StringFlag{
+			Name:  "image-proxy",
+			Usage: "Load images through this proxy, by appending the (query-escaped) image URL, e.g. https://proxy.example.com/?url=",
+		},
+		&cli.StringFlag{
+			Name:  "image-dir",
+			Usage: "Write inline images to this directory (named by the SHA-256 hash of their contents, so each is only stored once) and reference them by URL, instead of including them as data URIs",
+		},
+		&cli.StringFlag{
+			Name:  "image-url-prefix",
+			Usage: "The URL of the --image-dir directory, which images' file names are appended to, e.g. https://example.com/images/",
+		},
+		&cli.StringFlag{
+			Name:  "theme",
+			Value: terminal.BuildkiteTheme.Name,
+			Usage: "Colour theme used for --preview, --inline-styles, --format=svg and the emit-css command. One of: " + themeNames(),
+		},
+		&cli.BoolFlag{
+			Name:  "cast",
+			Usage: "The input is an asciicast v2 recording (from asciinema). Its final state is rendered",
+		},
+		&cli.BoolFlag{
+			Name:  "export-cast",
+			Usage: "Convert the input (a raw Buildkite job log) into an asciicast v2 recording instead of rendering it, using the timestamps in the log as event times",
+		},
+		&cli.StringFlag{
+			Name:  "alt-screen",
+			Value: "discard",
+			Usage: "What to do with the alternate screen contents (used by programs like vim, less and top) when the program exits. One of: discard, snapshot (keep the final contents as ordinary lines), inline (keep them as a collapsed block)",
+		},
 	}
 	app.Action = func(c *cli.Context) error {
-		screen, err := terminal.NewScreen(
+		theme := terminal.ThemeByName(c.String("theme"))
+		if theme == nil {
+			return fmt.Errorf("find theme: unknown theme %q (available themes: %s)", c.String("theme"), themeNames())
+		}
+
+		altScreenPolicy, ok := altScreenPolicies[c.String("alt-screen")]
+		if !ok {
+			return fmt.Errorf("parse --alt-screen: unknown policy %q (want discard, snapshot or inline)", c.String("alt-screen"))
+		}
+
+		format, ok := formats[c.String("format")]
+		if !ok {
+			return fmt.Errorf("parse --format: unknown format %q (want html, json, ansi or svg)", c.String("format"))
+		}
+		if format != terminal.FormatHTML && c.Bool("preview") {
+			return fmt.Errorf("parse --preview: previews are only available for --format=html")
+		}
+
+		// Without an explicit theme, previews use the default stylesheet.
+		previewTheme := theme
+		if !c.IsSet("theme") {
+			previewTheme = nil
+		}
+
+		screenOpts := []terminal.ScreenOption{
 			terminal.WithMaxSize(c.Int("window-max-cols"), c.Int("buffer-max-lines")),
 			terminal.WithSize(c.Int("window-cols"), c.Int("window-lines")),
-		)
+			terminal.WithHTMLOptions(terminal.HTMLOptions{
+				InlineStyles: c.Bool("inline-styles"),
+				Theme:        theme,
+				Sections:     c.Bool("sections"),
+				LineAnchors:  c.Bool("line-anchors"),
+				LineNumbers:  c.Bool("line-numbers"),
+				Linkify: terminal.LinkifyOptions{
+					URLs:     c.Bool("linkify"),
+					FileURL:  c.String("file-url"),
+					IssueURL: c.String("issue-url"),
+				},
+				URLPolicy: urlPolicy(c),
+				Safe:      c.Bool("safe"),
+			}),
+			terminal.WithAltScreenPolicy(altScreenPolicy),
+			terminal.WithLimits(terminal.Limits{
+				MaxImageBytes:      c.Int("max-image-bytes"),
+				MaxElementsPerLine: c.Int("max-elements-per-line"),
+				MaxElements:        c.Int("max-elements"),
+				MaxSequenceBytes:   c.Int("max-sequence-bytes"),
+			}),
+		}
+		if format != terminal.FormatSVG {
+			screenOpts = append(screenOpts, terminal.WithFormat(format))
+		}
+		if size := c.String("pty-size"); size != "" {
+			var cols, lines int
+			if _, err := fmt.Sscanf(size, "%dx%d", &cols, &lines); err != nil {
+				return fmt.Errorf("parse --pty-size %q: %w", size, err)
+			}
+			screenOpts = append(screenOpts, terminal.WithPTYSize(cols, lines))
+		}
+
+		if dir := c.String("image-dir"); dir != "" {
+			if err := os.MkdirAll(dir, 0o755); err != nil {
+				return fmt.Errorf("parse --image-dir: %w", err)
+			}
+			screenOpts = append(screenOpts, terminal.WithImageSink(terminal.DirImageSink{
+				Dir:       dir,
+				URLPrefix: c.String("image-url-prefix"),
+			}))
+		} else if c.String("image-url-prefix") != "" {
+			return fmt.Errorf("parse --image-url-prefix: requires --image-dir")
+		}
+
+		screen, err := terminal.NewScreen(screenOpts...)
 		if err != nil {
 			return fmt.Errorf("creating screen: %w", err)
 		}
 
+		exportCast := c.Bool("export-cast")
+		if exportCast && (c.Bool("cast") || c.Bool("preview") || c.String("http") != "") {
+			return fmt.Errorf("parse --export-cast: can't be used with --cast, --preview or --http")
+		}
+
 		// Run a web server?
 		if addr := c.String("http"); addr != "" {
-			webservice(addr, c.Bool("preview"), screen)
+			webservice(addr, c.Bool("preview"), previewTheme, format, c.Bool("cast"), screen)
 			return nil
 		}
 
@@ -283,7 +555,14 @@ func main() {
 			input = f
 		}
 
-		in, out, err := process(os.Stdout, input, c.Bool("preview"), screen)
+		if exportCast {
+			if err := terminal.ExportCast(os.Stdout, input, screenOpts...); err != nil {
+				return fmt.Errorf("export asciicast: %w", err)
+			}
+			return nil
+		}
+
+		in, out, err := process(os.Stdout, input, c.Bool("preview"), previewTheme, format, c.Bool("cast"), screen)
 		if err != nil {
 			return err
 		}
@@ -293,6 +572,32 @@ func main() {
 		return nil
 	}
 
+	app.Commands = []*cli.Command{
+		{
+			Name:  "emit-css",
+			Usage: "Write the stylesheet for a theme to stdout",
+			Flags: []cli.Flag{
+				&cli.StringFlag{
+					Name:  "theme",
+					Usage: "Colour theme to write the stylesheet for (default: the --theme given before emit-css, or " + terminal.BuildkiteTheme.Name + "). One of: " + themeNames(),
+				},
+			},
+			Action: func(c *cli.Context) error {
+				// The theme can be given before or after the command name.
+				name := c.String("theme")
+				if name == "" {
+					name = c.Lineage()[1].String("theme")
+				}
+				theme := terminal.ThemeByName(name)
+				if theme == nil {
+					return fmt.Errorf("find theme: unknown theme %q (available themes: %s)", name, themeNames())
+				}
+				_, err := io.WriteString(os.Stdout, theme.CSS())
+				return err
+			},
+		},
+	}
+
 	if err := app.Run(os.Args); err != nil {
 		log.Fatalf("Couldn't %v", err)
 	}
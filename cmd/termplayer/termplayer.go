@@ -1,5 +1,6 @@
 // termplayer outputs the contents of a file "slowly". It "plays back" raw
-// Buildkite job logs as though the job was running in a local terminal.
+// Buildkite job logs (or asciicast v2 recordings) as though the job was
+// running in a local terminal.
 package main
 
 import (
@@ -11,11 +12,15 @@ import (
 	"regexp"
 	"strconv"
 	"time"
+
+	"github.com/buildkite/terminal-to-html/v3"
 )
 
 var (
 	buildkiteMode = flag.Bool("bk", true, "If the file contains BK metadata, emit output at times corresponding to embedded timestamps instead of at a fixed rate")
-	speed         = flag.Int("speed", 1, "Rate of lines emitted per second. In BK mode, this multiplies the output speed")
+	castMode      = flag.Bool("cast", false, "The file is an asciicast v2 recording (from asciinema). Emit output at the recorded times")
+	speed         = flag.Int("speed", 1, "Rate of lines emitted per second. In BK and cast modes, this multiplies the output speed")
+	idleTimeLimit = flag.Duration("idle-time-limit", 0, "In cast mode, the longest pause between outputs (before applying -speed). 0 uses the recording's idle_time_limit, if it has one")
 )
 
 var buildkiteRE = regexp.MustCompile(`^_bk;t=(\d+)$`)
@@ -34,13 +39,50 @@ func main() {
 	}
 
 	rd := bufio.NewReader(input)
-	if *buildkiteMode {
+	switch {
+	case *castMode:
+		castModeOutput(rd)
+	case *buildkiteMode:
 		buildkiteModeOutput(rd)
-	} else {
+	default:
 		fixedRateOutput(rd)
 	}
 }
 
+func castModeOutput(rd io.Reader) {
+	cr, err := terminal.NewCastReader(rd)
+	if err != nil {
+		log.Fatalf("Reading cast: %v", err)
+	}
+	limit := *idleTimeLimit
+	if limit == 0 && cr.Header.IdleTimeLimit > 0 {
+		limit = time.Duration(cr.Header.IdleTimeLimit * float64(time.Second))
+	}
+
+	var last float64
+	for {
+		e, err := cr.Next()
+		if err == io.EOF {
+			return
+		}
+		if err != nil {
+			log.Fatalf("Reading cast: %v", err)
+		}
+		if e.Type != terminal.CastOutput {
+			continue
+		}
+		dt := time.Duration((e.Time - last) * float64(time.Second))
+		last = e.Time
+		if limit > 0 && dt > limit {
+			dt = limit
+		}
+		if dt > 0 {
+			time.Sleep(dt / time.Duration(*speed))
+		}
+		os.Stdout.WriteString(e.Data)
+	}
+}
+
 func buildkiteModeOutput(rd *bufio.Reader) {
 	var lastTS int
 	for {
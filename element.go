@@ -5,7 +5,9 @@ import (
 	"errors"
 	"fmt"
 	"html"
+	"io"
 	"mime"
+	"regexp"
 	"strings"
 )
 
@@ -14,6 +16,7 @@ const (
 	elementITermLink
 	elementImage
 	elementLink
+	elementAltScreen
 )
 
 type element struct {
@@ -24,19 +27,51 @@ type element struct {
 	height      string
 	width       string
 	elementType int
+
+	// For elementAltScreen, the contents of the alternate screen.
+	lines []screenLine
+}
+
+// urlKind returns the kind of URL the element has.
+func (i *element) urlKind() URLKind {
+	if i.elementType == elementImage {
+		return URLImage
+	}
+	return URLLink
 }
 
 var errUnsupportedElementSequence = errors.New("Unsupported element sequence")
 
-func (i *element) asHTML() string {
+// In safe mode, inline images must be one of these (raster) types, and image
+// sizes must be simple lengths.
+var (
+	safeImageTypes = map[string]bool{
+		"image/png":  true,
+		"image/jpeg": true,
+		"image/gif":  true,
+		"image/webp": true,
+	}
+	safeImageDimension = regexp.MustCompile(`^[0-9]+(\.[0-9]+)?(px|em|%)$`)
+)
+
+func (i *element) asHTML(opts HTMLOptions) string {
 	h := html.EscapeString
 
+	if i.elementType == elementAltScreen {
+		content := strings.TrimSuffix(linesToHTML(i.lines, opts), "\n")
+		if opts.Safe {
+			// No <details> block.
+			return content
+		}
+		return `<details class="term-alt-screen"><summary>Alternate screen</summary>` + content + `</details>`
+	}
+
 	if i.elementType == elementLink {
 		content := i.content
 		if content == "" {
 			content = i.url
 		}
-		return fmt.Sprintf(`<a href="%s">%s</a>`, h(sanitizeURL(i.url)), h(content))
+		return fmt.Sprintf(`<a href="%s"%s>%s</a>`, h(opts.url(i.url, URLLink)), opts.URLPolicy.linkAttrs(), h(content))
 	}
 
 	alt := i.alt
@@ -48,11 +83,18 @@ func (i *element) asHTML() string {
 
 	switch i.elementType {
 	case elementITermImage:
+		if opts.Safe && !safeImageTypes[i.contentType] {
+			return ""
+		}
 		src := fmt.Sprintf(`src="data:%s;base64,%s"`, h(i.contentType), h(i.content))
 		parts = append(parts, src)
 
 	case elementImage:
-		url := sanitizeURL(i.url)
+		// Inline images that were stored by an image sink keep their type.
+		if opts.Safe && i.contentType != "" && !safeImageTypes[i.contentType] {
+			return ""
+		}
+		url := opts.url(i.url, URLImage)
 		if url == "" || url == unsafeURLSubstitution {
 			// don't emit an <img> at all if the URL is empty or didn't sanitize
 			return ""
@@ -65,10 +107,10 @@ func (i *element) asHTML() string {
 		return ""
 	}
 
-	if i.width != "" {
+	if i.width != "" && (!opts.Safe || safeImageDimension.MatchString(i.width)) {
 		parts = append(parts, fmt.Sprintf(`width="%s"`, h(i.width)))
 	}
-	if i.height != "" {
+	if i.height != "" && (!opts.Safe || safeImageDimension.MatchString(i.height)) {
 		parts = append(parts, fmt.Sprintf(`height="%s"`, h(i.height)))
 	}
 
@@ -211,7 +253,8 @@ func splitAndVerifyElementSequence(s string) (arguments string, elementType int,
 		return "", 0, "", fmt.Errorf("image content missing")
 	}
 
-	_, err = base64.StdEncoding.DecodeString(content)
+	// Decode without keeping the result, which could be large.
+	_, err = io.Copy(io.Discard, base64.NewDecoder(base64.StdEncoding, strings.NewReader(content)))
 	if err != nil {
 		return "", 0, "", fmt.Errorf("expected content part to be valid Base64")
 	}
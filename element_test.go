@@ -222,7 +222,7 @@ var asHTMLCases = []struct {
 func TestAsHTMLCases(t *testing.T) {
 	for _, c := range asHTMLCases {
 		t.Run(c.name, func(t *testing.T) {
-			html := c.element.asHTML()
+			html := c.element.asHTML(HTMLOptions{})
 			if diff := cmp.Diff(html, c.expected); diff != "" {
 				t.Errorf("%v.asHTML() diff (-got +want):\n%s", c.element, diff)
 			}
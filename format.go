@@ -0,0 +1,63 @@
+package terminal
+
+import "fmt"
+
+// Format is an output format for screen contents.
+type Format int
+
+const (
+	// FormatHTML is HTML, as returned by AsHTML.
+	FormatHTML Format = iota
+
+	// FormatJSON is JSON Lines, as returned by AsJSON.
+	FormatJSON
+
+	// FormatANSI is normalised ANSI, as returned by AsANSI.
+	FormatANSI
+
+	// FormatSVG is an SVG image, as returned by AsSVG. An image can't be
+	// streamed, so it can't be used with WithFormat.
+	FormatSVG
+)
+
+// WithFormat sets the format of the lines passed to ScrollOutFunc. The
+// default is FormatHTML.
+func WithFormat(f Format) ScreenOption {
+	return func(s *Screen) error {
+		switch f {
+		case FormatHTML, FormatJSON, FormatANSI:
+		case FormatSVG:
+			return fmt.Errorf("SVG output can't be streamed")
+		default:
+			return fmt.Errorf("unknown format %d", f)
+		}
+		s.format = f
+		return nil
+	}
+}
+
+// AsFormat returns the contents of the current screen buffer in the given
+// format. SVG images use the theme and URL policy from the HTML options.
+func (s *Screen) AsFormat(f Format) string {
+	switch f {
+	case FormatJSON:
+		return s.AsJSON()
+	case FormatANSI:
+		return s.AsANSI()
+	case FormatSVG:
+		return s.AsSVG(SVGOptions{Theme: s.htmlOptions.Theme, URLPolicy: s.htmlOptions.URLPolicy})
+	}
+	return s.AsHTML()
+}
+
+// lineToFormat renders the parts of a line in the scroll-out format. The
+// output will have a terminating \n (except for HTML section tags).
+func (s *Screen) lineToFormat(parts []screenLine) string {
+	switch s.format {
+	case FormatJSON:
+		return lineToJSON(parts, s.htmlOptions.URLPolicy)
+	case FormatANSI:
+		return lineToANSI(parts, s.htmlOptions.URLPolicy)
+	}
+	return s.html.line(parts, s.htmlOptions)
+}
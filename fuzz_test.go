@@ -0,0 +1,139 @@
+package terminal
+
+import (
+	"io"
+	"net/url"
+	"slices"
+	"strings"
+	"testing"
+
+	"golang.org/x/net/html"
+)
+
+// The elements and attributes allowed in safe mode.
+var safeElements = map[string][]string{
+	"span": {"class", "style", "id"},
+	"a":    {"href", "rel", "target", "class"},
+	"img":  {"alt", "src", "width", "height"},
+	"time": {"datetime"},
+}
+
+// checkSafeHTML checks that out is well-formed HTML that only uses the
+// elements, attributes and URLs allowed in safe mode.
+func checkSafeHTML(t *testing.T, out string) {
+	t.Helper()
+
+	var stack []string
+	z := html.NewTokenizer(strings.NewReader(out))
+	for {
+		tt := z.Next()
+		switch tt {
+		case html.ErrorToken:
+			if err := z.Err(); err != io.EOF {
+				t.Fatalf("tokenizing output: %v\noutput: %q", err, out)
+			}
+			if len(stack) > 0 {
+				t.Fatalf("unclosed tags %v\noutput: %q", stack, out)
+			}
+			return
+
+		case html.StartTagToken, html.SelfClosingTagToken:
+			tok := z.Token()
+			allowed, ok := safeElements[tok.Data]
+			if !ok {
+				t.Fatalf("disallowed element <%s>\noutput: %q", tok.Data, out)
+			}
+			for _, a := range tok.Attr {
+				if !slices.Contains(allowed, a.Key) {
+					t.Fatalf("disallowed attribute %s on <%s>\noutput: %q", a.Key, tok.Data, out)
+				}
+				if a.Key == "href" || a.Key == "src" {
+					checkSafeURL(t, tok.Data, a.Val, out)
+				}
+			}
+			// img is a void element, so it isn't closed.
+			if tt == html.StartTagToken && tok.Data != "img" {
+				stack = append(stack, tok.Data)
+			}
+
+		case html.EndTagToken:
+			tok := z.Token()
+			if len(stack) == 0 || stack[len(stack)-1] != tok.Data {
+				t.Fatalf("</%s> doesn't close the open tag (open tags: %v)\noutput: %q", tok.Data, stack, out)
+			}
+			stack = stack[:len(stack)-1]
+
+		case html.CommentToken, html.DoctypeToken:
+			t.Fatalf("unexpected %v token\noutput: %q", tt, out)
+		}
+	}
+}
+
+// checkSafeURL checks that the URL of a link or image is allowed in safe mode.
+func checkSafeURL(t *testing.T, element, u, out string) {
+	t.Helper()
+
+	if element == "img" && strings.HasPrefix(u, "data:") {
+		mediaType, _, _ := strings.Cut(strings.TrimPrefix(u, "data:"), ";")
+		if !safeImageTypes[mediaType] {
+			t.Fatalf("disallowed inline image type %q\noutput: %q", mediaType, out)
+		}
+		return
+	}
+	parsed, err := url.Parse(u)
+	if err != nil {
+		t.Fatalf("url.Parse(%q) = %v\noutput: %q", u, err, out)
+	}
+	kind := URLLink
+	if element == "img" {
+		kind = URLImage
+	}
+	if parsed.Scheme != "" && !slices.Contains(safeSchemes[kind], parsed.Scheme) {
+		t.Fatalf("disallowed URL %q in <%s>\noutput: %q", u, element, out)
+	}
+}
+
+// safeFuzzSeeds are inputs that exercise elements, links and other features
+// that produce HTML tags.
+var safeFuzzSeeds = []string{
+	"plain text <script>alert(1)</script> & \"quotes\"",
+	"\x1b[1;31mbold red\x1b[0m \x1b[38;5;208;48;2;1;2;3mcolours\x1b[7;8mreversed and concealed",
+	"\x1b]8;;javascript:alert(1)\x1b\\link\x1b]8;;\x1b\\ \x1b]8;;https://example.com/\"onmouseover=\"x\x1b\\link\x1b]8;;\x1b\\",
+	"\x1b]1339;url=vbscript:msgbox(1);content=<b>bold</b>\x07",
+	"\x1b]1338;url=data:image/svg+xml,<svg onload=alert(1)>;alt=<script>\x07",
+	"\x1b]1338;url=https://example.com/a.png;alt=\\\"><script>;width=1\\\" onerror=\\\"x;height=100%\x07",
+	"\x1b]1337;File=name=YS5zdmc=;inline=1:PHN2Zz48L3N2Zz4=\x07",
+	"\x1b]1337;File=name=YS5wbmc=;inline=1;width=10\\\";height=5px:AAAA\x07",
+	"\x1b_bk;t=1700000000000\x07--- section\n::group::group\nhttps://example.com #1 main.go:1\n::endgroup::\n",
+	"\x1b[?1049hinside the alternate screen\x1b[?1049l",
+	"control \x00\x01\x7f\u0085 characters \u200b\ufeff\ufffe",
+	"wide 漢字 and combining é",
+}
+
+func FuzzScreenWriteSafeHTML(f *testing.F) {
+	for _, seed := range safeFuzzSeeds {
+		f.Add([]byte(seed))
+	}
+	for _, base := range TestFiles {
+		f.Add(loadFixture(f, base, "raw"))
+	}
+
+	opts := HTMLOptions{
+		Safe:        true,
+		Sections:    true,
+		LineNumbers: true,
+		Linkify:     LinkifyOptions{URLs: true, FileURL: "https://example.com/{path}#L{line}", IssueURL: "https://example.com/issues/{issue}"},
+	}
+
+	f.Fuzz(func(t *testing.T, input []byte) {
+		s, err := NewScreen(WithSize(40, 10), WithMaxSize(0, 20), WithHTMLOptions(opts), WithAltScreenPolicy(AltScreenInline))
+		if err != nil {
+			t.Fatalf("NewScreen() = %v", err)
+		}
+		var scrolledOut strings.Builder
+		s.ScrollOutFunc = func(line string) { scrolledOut.WriteString(line) }
+		s.Write(input)
+
+		checkSafeHTML(t, scrolledOut.String()+s.AsHTML())
+	})
+}
@@ -0,0 +1,145 @@
+package terminal
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// ImageSink stores inline images (from iTerm2's 1337 sequence) outside the
+// output, so that they can be referenced by URL instead of being included as
+// data URIs.
+//
+// Only PNG, JPEG, GIF and WebP images whose data matches their type are
+// passed to the sink, since anything else (such as HTML or SVG) could run
+// scripts when its URL is opened. Other images are replaced with an error.
+type ImageSink interface {
+	// StoreImage stores the image, and returns the URL to reference it by.
+	// The name is the image's file name, as given in the output, and
+	// contentType is "image/png", "image/jpeg", "image/gif" or "image/webp".
+	StoreImage(name, contentType string, data []byte) (url string, err error)
+}
+
+// imageExts are the types of image that can be stored, and their extensions.
+var imageExts = map[string]string{
+	"image/png":  ".png",
+	"image/jpeg": ".jpg",
+	"image/gif":  ".gif",
+	"image/webp": ".webp",
+}
+
+// imageHasType reports if the data starts with the signature of the image
+// type.
+func imageHasType(data []byte, contentType string) bool {
+	switch contentType {
+	case "image/png":
+		return bytes.HasPrefix(data, []byte("\x89PNG\r\n\x1a\n"))
+	case "image/jpeg":
+		return bytes.HasPrefix(data, []byte("\xff\xd8\xff"))
+	case "image/gif":
+		return bytes.HasPrefix(data, []byte("GIF87a")) || bytes.HasPrefix(data, []byte("GIF89a"))
+	case "image/webp":
+		return len(data) >= 12 && string(data[:4]) == "RIFF" && string(data[8:12]) == "WEBP"
+	}
+	return false
+}
+
+// WithImageSink hands inline images to the sink as they are written to the
+// screen. Images are then rendered with the URL returned by the sink, like
+// images from the 1338 sequence (so the URL policy applies to them).
+func WithImageSink(sink ImageSink) ScreenOption {
+	return func(s *Screen) error {
+		s.imageSink = sink
+		return nil
+	}
+}
+
+// storeImage hands an inline image to the image sink, and returns an element
+// that references it by URL instead.
+func (s *Screen) storeImage(e *element) (*element, error) {
+	// The type comes from the name the sender chose, so check it, and the
+	// data, before anything is stored.
+	if _, ok := imageExts[e.contentType]; !ok {
+		return nil, fmt.Errorf("%s is %s, but only PNG, JPEG, GIF and WebP images can be stored", e.url, e.contentType)
+	}
+	data, err := base64.StdEncoding.DecodeString(e.content)
+	if err != nil {
+		return nil, err
+	}
+	if !imageHasType(data, e.contentType) {
+		return nil, fmt.Errorf("%s doesn't contain %s data", e.url, e.contentType)
+	}
+	url, err := s.imageSink.StoreImage(e.url, e.contentType, data)
+	if err != nil {
+		return nil, err
+	}
+	alt := e.alt
+	if alt == "" {
+		alt = e.url
+	}
+	return &element{
+		elementType: elementImage,
+		url:         url,
+		alt:         alt,
+		width:       e.width,
+		height:      e.height,
+		contentType: e.contentType,
+	}, nil
+}
+
+// DirImageSink is an ImageSink that writes images to a directory. Files are
+// named by the SHA-256 hash of their contents (and the extension for their
+// type), so each distinct image is only stored once, however many times it is
+// output.
+type DirImageSink struct {
+	// Dir is the directory to write images to. It must exist.
+	Dir string
+
+	// URLPrefix is put before the file name to make the image's URL, for
+	// example "https://example.com/images/". If empty, the URL is the file
+	// name.
+	URLPrefix string
+}
+
+// StoreImage writes the image to the directory, unless it is already there.
+// Types other than PNG, JPEG, GIF and WebP are refused.
+func (d DirImageSink) StoreImage(name, contentType string, data []byte) (string, error) {
+	ext, ok := imageExts[contentType]
+	if !ok {
+		return "", fmt.Errorf("storing image %q: unsupported type %q", name, contentType)
+	}
+	sum := sha256.Sum256(data)
+	file := hex.EncodeToString(sum[:]) + ext
+	url := d.URLPrefix + file
+
+	dst := filepath.Join(d.Dir, file)
+	if _, err := os.Stat(dst); err == nil {
+		return url, nil
+	}
+
+	// Write to a temporary file first, so that the image never appears
+	// half-written.
+	tmp, err := os.CreateTemp(d.Dir, ".image-*")
+	if err != nil {
+		return "", fmt.Errorf("storing image %q: %w", name, err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return "", fmt.Errorf("storing image %q: %w", name, err)
+	}
+	if err := tmp.Close(); err != nil {
+		return "", fmt.Errorf("storing image %q: %w", name, err)
+	}
+	if err := os.Chmod(tmp.Name(), 0o644); err != nil {
+		return "", fmt.Errorf("storing image %q: %w", name, err)
+	}
+	if err := os.Rename(tmp.Name(), dst); err != nil {
+		return "", fmt.Errorf("storing image %q: %w", name, err)
+	}
+	return url, nil
+}
@@ -0,0 +1,197 @@
+package terminal
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+// mapImageSink stores images in a map, keyed by their URL.
+type mapImageSink map[string][]byte
+
+func (m mapImageSink) StoreImage(name, contentType string, data []byte) (string, error) {
+	url := "https://images.example.com/" + contentType + "/" + name
+	m[url] = data
+	return url, nil
+}
+
+type errImageSink struct{}
+
+func (errImageSink) StoreImage(name, contentType string, data []byte) (string, error) {
+	return "", errors.New("disk full")
+}
+
+// pngData is the start of a PNG image, which is enough to pass the type check.
+const pngData = "\x89PNG\r\n\x1a\nhi"
+
+func TestImageSink(t *testing.T) {
+	pngImage := "\x1b]1337;File=name=" + base64Encode("a.png") + ";inline=1:" + base64Encode(pngData) + "\x07"
+
+	tests := []struct {
+		name      string
+		opts      HTMLOptions
+		input     string
+		want      string
+		wantStore map[string][]byte
+	}{
+		{
+			name:      "image",
+			input:     "\x1b]1337;File=name=" + base64Encode("a.png") + ";inline=1;width=10px:" + base64Encode(pngData) + "\x07",
+			want:      `<img alt="a.png" src="https://images.example.com/image/png/a.png" width="10px">`,
+			wantStore: map[string][]byte{"https://images.example.com/image/png/a.png": []byte(pngData)},
+		},
+		{
+			name:      "URL policy",
+			opts:      HTMLOptions{URLPolicy: &URLPolicy{AllowedHosts: []string{"example.com"}}},
+			input:     pngImage,
+			want:      "&nbsp;",
+			wantStore: map[string][]byte{"https://images.example.com/image/png/a.png": []byte(pngData)},
+		},
+		{
+			name:      "HTML isn't stored",
+			input:     "\x1b]1337;File=name=" + base64Encode("x.html") + ";inline=1:" + base64Encode("<script>alert(1)</script>") + "\x07",
+			want:      "*** Error storing image: x.html is text&#47;html; charset=utf-8, but only PNG, JPEG, GIF and WebP images can be stored",
+			wantStore: map[string][]byte{},
+		},
+		{
+			name:      "SVG isn't stored",
+			input:     "\x1b]1337;File=name=" + base64Encode("a.svg") + ";inline=1:" + base64Encode("<svg></svg>") + "\x07",
+			want:      "*** Error storing image: a.svg is image&#47;svg+xml, but only PNG, JPEG, GIF and WebP images can be stored",
+			wantStore: map[string][]byte{},
+		},
+		{
+			name:      "data that doesn't match the type isn't stored",
+			input:     "\x1b]1337;File=name=" + base64Encode("a.png") + ";inline=1:" + base64Encode("<script>alert(1)</script>") + "\x07",
+			want:      "*** Error storing image: a.png doesn&#39;t contain image&#47;png data",
+			wantStore: map[string][]byte{},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			sink := mapImageSink{}
+			s, err := NewScreen(WithImageSink(sink), WithHTMLOptions(test.opts))
+			if err != nil {
+				t.Fatalf("NewScreen() = %v", err)
+			}
+			s.Write([]byte(test.input))
+			if diff := cmp.Diff(s.AsHTML(), test.want); diff != "" {
+				t.Errorf("s.AsHTML() diff (-got +want):\n%s", diff)
+			}
+			if diff := cmp.Diff(map[string][]byte(sink), test.wantStore); diff != "" {
+				t.Errorf("stored images diff (-got +want):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestImageSinkJSON(t *testing.T) {
+	s, err := NewScreen(WithImageSink(mapImageSink{}))
+	if err != nil {
+		t.Fatalf("NewScreen() = %v", err)
+	}
+	s.Write([]byte("\x1b]1337;File=name=" + base64Encode("a.png") + ";inline=1:" + base64Encode(pngData) + "\x07"))
+
+	want := `{"runs":[{"element":{"type":"image","url":"https://images.example.com/image/png/a.png","alt":"a.png","content_type":"image/png"}}]}` + "\n"
+	if diff := cmp.Diff(s.AsJSON(), want); diff != "" {
+		t.Errorf("s.AsJSON() diff (-got +want):\n%s", diff)
+	}
+}
+
+func TestImageSinkError(t *testing.T) {
+	s, err := NewScreen(WithImageSink(errImageSink{}))
+	if err != nil {
+		t.Fatalf("NewScreen() = %v", err)
+	}
+	s.Write([]byte("before\x1b]1337;File=name=" + base64Encode("a.png") + ";inline=1:" + base64Encode(pngData) + "\x07after"))
+
+	want := "before\n*** Error storing image: disk full\nafter"
+	if diff := cmp.Diff(s.AsHTML(), want); diff != "" {
+		t.Errorf("s.AsHTML() diff (-got +want):\n%s", diff)
+	}
+}
+
+// dirFiles returns the names of the files in dir.
+func dirFiles(t *testing.T, dir string) []string {
+	t.Helper()
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("os.ReadDir(%q) = %v", dir, err)
+	}
+	var files []string
+	for _, e := range entries {
+		files = append(files, e.Name())
+	}
+	return files
+}
+
+func TestDirImageSink(t *testing.T) {
+	dir := t.TempDir()
+	sink := DirImageSink{Dir: dir, URLPrefix: "/images/"}
+
+	// sha256("hi")
+	const hash = "8f434346648f6b96df89dda901c5176b10a6d83961dd3c1ac88b59b2dc327aa4"
+
+	tests := []struct {
+		name, file, contentType string
+		want                    string
+	}{
+		{name: "first", file: "a.PNG", contentType: "image/png", want: "/images/" + hash + ".png"},
+		{name: "same content and type", file: "b.png", contentType: "image/png", want: "/images/" + hash + ".png"},
+		{name: "extension from the type", file: "c.html", contentType: "image/jpeg", want: "/images/" + hash + ".jpg"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := sink.StoreImage(test.file, test.contentType, []byte("hi"))
+			if err != nil {
+				t.Fatalf("sink.StoreImage(%q, %q) error = %v", test.file, test.contentType, err)
+			}
+			if got != test.want {
+				t.Errorf("sink.StoreImage(%q, %q) = %q, want %q", test.file, test.contentType, got, test.want)
+			}
+		})
+	}
+
+	if diff := cmp.Diff(dirFiles(t, dir), []string{hash + ".jpg", hash + ".png"}); diff != "" {
+		t.Errorf("files in image dir diff (-got +want):\n%s", diff)
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, hash+".png"))
+	if err != nil {
+		t.Fatalf("os.ReadFile() = %v", err)
+	}
+	if got, want := string(data), "hi"; got != want {
+		t.Errorf("image contents = %q, want %q", got, want)
+	}
+}
+
+func TestDirImageSinkRefusesUnsafeTypes(t *testing.T) {
+	dir := t.TempDir()
+	s, err := NewScreen(WithImageSink(DirImageSink{Dir: dir}))
+	if err != nil {
+		t.Fatalf("NewScreen() = %v", err)
+	}
+	s.Write([]byte("\x1b]1337;File=name=" + base64Encode("x.html") + ";inline=1:" + base64Encode("<script>alert(1)</script>") + "\x07\n"))
+	s.Write([]byte("\x1b]1337;File=name=" + base64Encode("a.svg") + ";inline=1:" + base64Encode("<svg onload=alert(1)></svg>") + "\x07\n"))
+
+	// Even when called directly.
+	for _, contentType := range []string{"text/html; charset=utf-8", "image/svg+xml"} {
+		if _, err := (DirImageSink{Dir: dir}).StoreImage("x", contentType, []byte("<script>alert(1)</script>")); err == nil {
+			t.Errorf("StoreImage(%q) error = nil, want error", contentType)
+		}
+	}
+
+	if files := dirFiles(t, dir); len(files) > 0 {
+		t.Errorf("files in image dir = %q, want none", files)
+	}
+}
+
+func TestDirImageSinkMissingDir(t *testing.T) {
+	sink := DirImageSink{Dir: filepath.Join(t.TempDir(), "missing")}
+	if _, err := sink.StoreImage("a.png", "image/png", []byte("hi")); err == nil {
+		t.Errorf("sink.StoreImage() error = nil, want error")
+	}
+}
@@ -0,0 +1,252 @@
+package terminal
+
+import (
+	"bytes"
+	"encoding/json"
+	"maps"
+	"strings"
+)
+
+// JSONLine is a line of output, as encoded by AsJSON. Lines that were wrapped
+// in the terminal are joined together into one JSONLine.
+type JSONLine struct {
+	// Metadata is the bk metadata for the line (e.g. "t" for the timestamp),
+	// merged in the same way as for HTML.
+	Metadata map[string]string `json:"metadata,omitempty"`
+
+	// Runs are the consecutive pieces of text that share the same style and
+	// link, and elements (such as images) in between.
+	Runs []JSONRun `json:"runs"`
+}
+
+// JSONRun is a run of text with the same style and link, or an element.
+type JSONRun struct {
+	Text string `json:"text,omitempty"`
+
+	FG *JSONColor `json:"fg,omitempty"`
+	BG *JSONColor `json:"bg,omitempty"`
+
+	Bold      bool `json:"bold,omitempty"`
+	Faint     bool `json:"faint,omitempty"`
+	Italic    bool `json:"italic,omitempty"`
+	Underline bool `json:"underline,omitempty"`
+	Strike    bool `json:"strike,omitempty"`
+	Blink     bool `json:"blink,omitempty"`
+	Reverse   bool `json:"reverse,omitempty"`
+	Conceal   bool `json:"conceal,omitempty"`
+
+	// Link is the URL of an OSC 8 hyperlink, sanitized and checked against
+	// the URL policy.
+	Link string `json:"link,omitempty"`
+
+	// Element is set instead of Text for images, links and other elements.
+	Element *JSONElement `json:"element,omitempty"`
+}
+
+// JSONColor is a foreground or background colour.
+type JSONColor struct {
+	// Type is "sgr" for the basic 16 colours, "8bit" for the 256 colour
+	// palette, or "24bit".
+	Type string `json:"type"`
+
+	// Value is the SGR parameter for "sgr" colours (e.g. 31 for a red
+	// foreground, or 101 for a bright red background), the palette index for
+	// "8bit" colours, or 0xRRGGBB for "24bit" colours.
+	Value int `json:"value"`
+
+	// RGB is the "#rrggbb" form of "24bit" colours.
+	RGB string `json:"rgb,omitempty"`
+}
+
+// JSONElement is an element such as an image or a link.
+type JSONElement struct {
+	// Type is "image", "link" or "alt-screen".
+	Type        string `json:"type"`
+	URL         string `json:"url,omitempty"`
+	Alt         string `json:"alt,omitempty"`
+	Width       string `json:"width,omitempty"`
+	Height      string `json:"height,omitempty"`
+	ContentType string `json:"content_type,omitempty"`
+
+	// Content is the link text for links, or the base64-encoded image for
+	// inline images.
+	Content string `json:"content,omitempty"`
+
+	// Lines are the contents of the alternate screen, for "alt-screen".
+	Lines []JSONLine `json:"lines,omitempty"`
+}
+
+// AsJSON returns the contents of the current screen buffer as JSON Lines:
+// one JSONLine object per line, each followed by a newline.
+func (s *Screen) AsJSON() string {
+	return linesToJSON(s.contents(), s.htmlOptions.URLPolicy)
+}
+
+// linesToJSON encodes screen lines as JSON Lines, joining wrapped lines
+// together. URLs are checked against the policy (which can be nil).
+func linesToJSON(screen []screenLine, p *URLPolicy) string {
+	var sb strings.Builder
+	for _, parts := range logicalLines(screen) {
+		sb.WriteString(lineToJSON(parts, p))
+	}
+	return sb.String()
+}
+
+// lineToJSON joins parts of a line together and encodes them as a JSON
+// object followed by a newline.
+func lineToJSON(parts []screenLine, p *URLPolicy) string {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	// JSONLine contains nothing that can fail to encode.
+	_ = enc.Encode(lineToJSONLine(parts, p))
+	return buf.String()
+}
+
+// lineToJSONLine converts parts of a line into a JSONLine.
+func lineToJSONLine(parts []screenLine, p *URLPolicy) JSONLine {
+	line := JSONLine{Runs: []JSONRun{}}
+
+	// Combine metadata - last metadata wins.
+	for _, l := range parts {
+		if md := l.metadata[bkNamespace]; len(md) > 0 {
+			if line.Metadata == nil {
+				line.Metadata = make(map[string]string)
+			}
+			maps.Copy(line.Metadata, md)
+		}
+	}
+
+	var text strings.Builder
+	var previous node
+	var previousLink string
+	flush := func() {
+		if text.Len() == 0 {
+			return
+		}
+		run := newJSONRun(previous.style)
+		run.Text = text.String()
+		run.Link = previousLink
+		line.Runs = append(line.Runs, run)
+		text.Reset()
+	}
+
+	for _, l := range parts {
+		for x, current := range l.nodes {
+			if current.style.wideTail() && !current.style.conceal() {
+				// The first column of the wide character was already written.
+				continue
+			}
+
+			var link string
+			if current.style.hyperlink() {
+				link = p.apply(l.hyperlinks[x], URLLink)
+			}
+			if !current.hasSameStyle(previous) || link != previousLink || current.style.element() {
+				flush()
+			}
+			previous, previousLink = current, link
+
+			switch {
+			case current.style.element():
+				run := newJSONRun(current.style)
+				run.Link = link
+				run.Element = l.elements[current.blob].asJSON(p)
+				line.Runs = append(line.Runs, run)
+			case current.style.conceal():
+				text.WriteRune(' ')
+			default:
+				text.WriteRune(current.blob)
+				text.WriteString(l.combining[x])
+			}
+		}
+	}
+	flush()
+
+	// Like HTML, trailing unstyled whitespace isn't interesting.
+	for len(line.Runs) > 0 {
+		last := &line.Runs[len(line.Runs)-1]
+		if last.Element != nil || !last.plain() {
+			break
+		}
+		last.Text = strings.TrimRight(last.Text, " \t")
+		if last.Text != "" {
+			break
+		}
+		line.Runs = line.Runs[:len(line.Runs)-1]
+	}
+	return line
+}
+
+// newJSONRun returns a JSONRun with the style set.
+func newJSONRun(s style) JSONRun {
+	return JSONRun{
+		FG:        jsonColor(s.fgColorType(), s.fgColor()),
+		BG:        jsonColor(s.bgColorType(), s.bgColor()),
+		Bold:      s.bold(),
+		Faint:     s.faint(),
+		Italic:    s.italic(),
+		Underline: s.underline(),
+		Strike:    s.strike(),
+		Blink:     s.blink(),
+		Reverse:   s.reverse(),
+		Conceal:   s.conceal(),
+	}
+}
+
+// plain reports if the run has no style or link.
+func (r *JSONRun) plain() bool {
+	return *r == JSONRun{Text: r.Text}
+}
+
+// jsonColor converts a colour from a style into a JSONColor, or nil if there
+// is no colour.
+func jsonColor(colorType uint8, c uint32) *JSONColor {
+	switch colorType {
+	case colorSGR:
+		return &JSONColor{Type: "sgr", Value: int(c)}
+	case color8Bit:
+		return &JSONColor{Type: "8bit", Value: int(c)}
+	case color24Bit:
+		return &JSONColor{Type: "24bit", Value: int(c), RGB: rgbHex(c)}
+	}
+	return nil
+}
+
+// asJSON converts the element into a JSONElement, checking its URL against the
+// policy.
+func (i *element) asJSON(p *URLPolicy) *JSONElement {
+	switch i.elementType {
+	case elementAltScreen:
+		e := &JSONElement{Type: "alt-screen", Lines: []JSONLine{}}
+		for _, parts := range logicalLines(i.lines) {
+			e.Lines = append(e.Lines, lineToJSONLine(parts, p))
+		}
+		return e
+
+	case elementLink:
+		return &JSONElement{Type: "link", URL: p.apply(i.url, URLLink), Content: i.content}
+
+	case elementITermImage:
+		return &JSONElement{
+			Type:        "image",
+			Alt:         i.alt,
+			Width:       i.width,
+			Height:      i.height,
+			ContentType: i.contentType,
+			Content:     i.content,
+		}
+
+	case elementImage:
+		return &JSONElement{
+			Type:   "image",
+			URL:    p.apply(i.url, URLImage),
+			Alt:    i.alt,
+			Width:  i.width,
+			Height: i.height,
+			// Set for inline images stored by an ImageSink.
+			ContentType: i.contentType,
+		}
+	}
+	return nil
+}
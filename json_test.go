@@ -0,0 +1,153 @@
+package terminal
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestScreenAsJSON(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{
+			name:  "plain text",
+			input: "hello\nworld   ",
+			want: `{"runs":[{"text":"hello"}]}
+{"runs":[{"text":"world"}]}
+`,
+		},
+		{
+			name:  "empty lines",
+			input: "a\n\nb",
+			want: `{"runs":[{"text":"a"}]}
+{"runs":[]}
+{"runs":[{"text":"b"}]}
+`,
+		},
+		{
+			name:  "SGR colours and attributes",
+			input: "\x1b[1;31mbold red\x1b[0m \x1b[3;104mitalic\x1b[0m",
+			want: `{"runs":[{"text":"bold red","fg":{"type":"sgr","value":31},"bold":true},{"text":" "},{"text":"italic","bg":{"type":"sgr","value":104},"italic":true}]}
+`,
+		},
+		{
+			name:  "8-bit and 24-bit colours",
+			input: "\x1b[38;5;208morange\x1b[48;2;1;2;255mblue bg",
+			want: `{"runs":[{"text":"orange","fg":{"type":"8bit","value":208}},{"text":"blue bg","fg":{"type":"8bit","value":208},"bg":{"type":"24bit","value":66303,"rgb":"#0102ff"}}]}
+`,
+		},
+		{
+			name:  "reverse keeps the original colours",
+			input: "\x1b[7;32mreversed",
+			want: `{"runs":[{"text":"reversed","fg":{"type":"sgr","value":32},"reverse":true}]}
+`,
+		},
+		{
+			name:  "concealed text is replaced with spaces",
+			input: "\x1b[8msecret\x1b[0m!",
+			want: `{"runs":[{"text":"      ","conceal":true},{"text":"!"}]}
+`,
+		},
+		{
+			name:  "hyperlinks split runs",
+			input: "a \x1b]8;;http://example.com/?a=1&b=2\x1b\\link\x1b]8;;\x1b\\ \x1b]8;;javascript:alert(1)\x1b\\bad\x1b]8;;\x1b\\",
+			want: `{"runs":[{"text":"a "},{"text":"link","link":"http://example.com/?a=1&b=2"},{"text":" "},{"text":"bad","link":"#"}]}
+`,
+		},
+		{
+			name:  "wrapped lines are joined",
+			input: "\x1b[8;24;4tabcdefghij",
+			want: `{"runs":[{"text":"abcdefghij"}]}
+`,
+		},
+		{
+			name:  "wide and combining characters",
+			input: "日本 é",
+			want: `{"runs":[{"text":"日本 é"}]}
+`,
+		},
+		{
+			name:  "metadata",
+			input: "\x1b_bk;t=123\x07hello \x1b_bk;t=456;foo=bar\x07world",
+			want: `{"metadata":{"foo":"bar","t":"456"},"runs":[{"text":"hello world"}]}
+`,
+		},
+		{
+			name:  "iTerm link element",
+			input: "see \x1b]1339;url=http://example.com;content=example\x07!",
+			want: `{"runs":[{"text":"see "},{"element":{"type":"link","url":"http://example.com","content":"example"}},{"text":"!"}]}
+`,
+		},
+		{
+			name:  "image element",
+			input: "\x1b]1338;url=http://example.com/a.png;alt=A picture;width=10;height=20\x07",
+			want: `{"runs":[{"element":{"type":"image","url":"http://example.com/a.png","alt":"A picture","width":"10em","height":"20em"}}]}
+`,
+		},
+		{
+			name:  "HTML characters are not escaped",
+			input: "<b>&amp;</b>",
+			want: `{"runs":[{"text":"<b>&amp;</b>"}]}
+`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			s, err := NewScreen()
+			if err != nil {
+				t.Fatalf("NewScreen() = %v", err)
+			}
+			s.Write([]byte(test.input))
+			if diff := cmp.Diff(s.AsJSON(), test.want); diff != "" {
+				t.Errorf("s.AsJSON() diff (-got +want):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestScreenAsJSONAltScreen(t *testing.T) {
+	s, err := NewScreen(WithAltScreenPolicy(AltScreenInline))
+	if err != nil {
+		t.Fatalf("NewScreen() = %v", err)
+	}
+	s.Write([]byte("$ less\n\x1b[?1049h\x1b[Hpage\x1b[?1049l$ done"))
+
+	want := `{"runs":[{"text":"$ less"}]}
+{"runs":[{"element":{"type":"alt-screen","lines":[{"runs":[{"text":"page"}]}]}}]}
+{"runs":[{"text":"$ done"}]}
+`
+	if diff := cmp.Diff(s.AsJSON(), want); diff != "" {
+		t.Errorf("s.AsJSON() diff (-got +want):\n%s", diff)
+	}
+}
+
+func TestScreenJSONScrollOut(t *testing.T) {
+	s, err := NewScreen(WithMaxSize(0, 2), WithFormat(FormatJSON))
+	if err != nil {
+		t.Fatalf("NewScreen() = %v", err)
+	}
+	var scrolledOut strings.Builder
+	s.ScrollOutFunc = func(line string) { scrolledOut.WriteString(line) }
+	s.Write([]byte("one\n\x1b[32mtwo\x1b[0m\nthree\nfour"))
+
+	got := scrolledOut.String() + s.AsFormat(FormatJSON)
+	want := `{"runs":[{"text":"one"}]}
+{"runs":[{"text":"two","fg":{"type":"sgr","value":32}}]}
+{"runs":[{"text":"three"}]}
+{"runs":[{"text":"four"}]}
+`
+	if diff := cmp.Diff(got, want); diff != "" {
+		t.Errorf("scrolled out + s.AsFormat(FormatJSON) diff (-got +want):\n%s", diff)
+	}
+}
+
+func TestWithFormatUnknown(t *testing.T) {
+	if _, err := NewScreen(WithFormat(Format(42))); err == nil {
+		t.Errorf("NewScreen(WithFormat(Format(42))) error = nil, want error")
+	}
+}
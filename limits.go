@@ -0,0 +1,75 @@
+package terminal
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strings"
+)
+
+// Limits bound the resources that input can use, such as a job printing a
+// huge inline image. Things over a limit are replaced with a placeholder.
+// Zero values mean no limit.
+type Limits struct {
+	// MaxImageBytes is the largest (decoded) size of an inline image, from
+	// iTerm2's 1337 sequence. Larger images are replaced with a placeholder.
+	MaxImageBytes int
+
+	// MaxElementsPerLine and MaxElements are the most elements (images and
+	// 1339 links) in a line, and written to the screen in total. Images over
+	// the limit are replaced with a placeholder, and links with their text.
+	MaxElementsPerLine int
+	MaxElements        int
+
+	// MaxSequenceBytes is the longest OSC (such as an inline image) or APC
+	// sequence. Longer sequences aren't buffered; the rest of the sequence is
+	// skipped, and a placeholder is written in its place.
+	MaxSequenceBytes int
+}
+
+// WithLimits sets limits on the resources that input can use.
+func WithLimits(l Limits) ScreenOption {
+	return func(s *Screen) error {
+		if l.MaxImageBytes < 0 || l.MaxElementsPerLine < 0 || l.MaxElements < 0 || l.MaxSequenceBytes < 0 {
+			return fmt.Errorf("limits can't be negative: %+v", l)
+		}
+		s.limits = l
+		return nil
+	}
+}
+
+// limitElement returns the element to write in place of e, or nil if a
+// placeholder was written instead, because e is over a limit.
+func (s *Screen) limitElement(e *element) *element {
+	if e.elementType == elementITermImage && s.limits.MaxImageBytes > 0 {
+		if n := decodedLen(e.content); n > s.limits.MaxImageBytes {
+			s.appendMany([]rune(fmt.Sprintf("*** Image omitted: %s is %d bytes, over the limit of %d bytes", e.url, n, s.limits.MaxImageBytes)))
+			return nil
+		}
+	}
+
+	var over string
+	switch {
+	case s.limits.MaxElements > 0 && s.elementsWritten >= s.limits.MaxElements:
+		over = fmt.Sprintf("over the limit of %d elements", s.limits.MaxElements)
+	case s.limits.MaxElementsPerLine > 0 && len(s.currentLineForWriting().elements) >= s.limits.MaxElementsPerLine:
+		over = fmt.Sprintf("over the limit of %d elements per line", s.limits.MaxElementsPerLine)
+	default:
+		s.elementsWritten++
+		return e
+	}
+
+	if e.elementType == elementLink {
+		// Links degrade to their text.
+		text, _ := e.asANSI()
+		s.appendMany([]rune(stripControls(text)))
+		return nil
+	}
+	s.appendMany([]rune("*** Image omitted: " + over))
+	return nil
+}
+
+// decodedLen returns the length of base64-encoded (and padded) data once it
+// is decoded.
+func decodedLen(b64 string) int {
+	return base64.StdEncoding.DecodedLen(len(b64)) - (len(b64) - len(strings.TrimRight(b64, "=")))
+}
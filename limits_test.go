@@ -0,0 +1,106 @@
+package terminal
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestLimits(t *testing.T) {
+	// name=a.png
+	const image = "\x1b]1337;File=name=YS5wbmc=;inline=1:AAAAAAAA\x07"
+
+	tests := []struct {
+		name   string
+		limits Limits
+		input  string
+		want   string
+	}{
+		{
+			name:   "image under the size limit",
+			limits: Limits{MaxImageBytes: 6},
+			input:  image,
+			want:   `<img alt="a.png" src="data:image/png;base64,AAAAAAAA">`,
+		},
+		{
+			name:   "image over the size limit",
+			limits: Limits{MaxImageBytes: 5},
+			input:  image + "after",
+			want:   "*** Image omitted: a.png is 6 bytes, over the limit of 5 bytes\nafter",
+		},
+		{
+			name:   "elements per line",
+			limits: Limits{MaxElementsPerLine: 2},
+			input:  "\x1b]1339;url=/1;content=one\x07 \x1b]1339;url=/2;content=two\x07 \x1b]1339;url=/3;content=three\x07\n\x1b]1339;url=/4;content=four\x07",
+			want:   `<a href="/1">one</a> <a href="/2">two</a> three` + "\n" + `<a href="/4">four</a>`,
+		},
+		{
+			name:   "elements in total",
+			limits: Limits{MaxElements: 1},
+			input:  image + image,
+			want:   `<img alt="a.png" src="data:image/png;base64,AAAAAAAA">` + "\n*** Image omitted: over the limit of 1 elements",
+		},
+		{
+			name:   "OSC over the length limit",
+			limits: Limits{MaxSequenceBytes: 20},
+			input:  "before " + image + "\nafter",
+			want:   "before *** Escape sequence omitted: over the limit of 20 bytes\nafter",
+		},
+		{
+			name:   "APC over the length limit",
+			limits: Limits{MaxSequenceBytes: 20},
+			input:  "\x1b_bk;t=1700000000000;x=" + strings.Repeat("y", 100) + "\x1b\\\nafter",
+			want:   "*** Escape sequence omitted: over the limit of 20 bytes\nafter",
+		},
+		{
+			name:   "sequences under the length limit",
+			limits: Limits{MaxSequenceBytes: 60},
+			input:  "\x1b_bk;t=1700000000000\x07" + image,
+			want:   `<time datetime="2023-11-14T22:13:20Z">2023-11-14T22:13:20Z</time><img alt="a.png" src="data:image/png;base64,AAAAAAAA">`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			s, err := NewScreen(WithLimits(test.limits))
+			if err != nil {
+				t.Fatalf("NewScreen() = %v", err)
+			}
+			s.Write([]byte(test.input))
+			if diff := cmp.Diff(s.AsHTML(), test.want); diff != "" {
+				t.Errorf("s.AsHTML() diff (-got +want):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestLimitsSequenceNotBuffered(t *testing.T) {
+	s, err := NewScreen(WithLimits(Limits{MaxSequenceBytes: 100}))
+	if err != nil {
+		t.Fatalf("NewScreen() = %v", err)
+	}
+
+	// Write a long image sequence in small chunks, with the terminator split
+	// across writes.
+	s.Write([]byte("\x1b]1337;File=name=YS5wbmc=;inline=1:"))
+	for range 1000 {
+		s.Write([]byte(strings.Repeat("AAAA", 10)))
+		if got := len(s.parser.remainder); got > 100 {
+			t.Fatalf("len(s.parser.remainder) = %d, want <= 100", got)
+		}
+	}
+	s.Write([]byte("\x1b"))
+	s.Write([]byte("\\\nafter"))
+
+	want := "*** Escape sequence omitted: over the limit of 100 bytes\nafter"
+	if diff := cmp.Diff(s.AsPlainText(), want); diff != "" {
+		t.Errorf("s.AsPlainText() diff (-got +want):\n%s", diff)
+	}
+}
+
+func TestWithLimitsNegative(t *testing.T) {
+	if _, err := NewScreen(WithLimits(Limits{MaxImageBytes: -1})); err == nil {
+		t.Errorf("NewScreen(WithLimits(Limits{MaxImageBytes: -1})) error = nil, want error")
+	}
+}
@@ -0,0 +1,178 @@
+package terminal
+
+import (
+	"maps"
+	"net/url"
+	"regexp"
+	"slices"
+	"strings"
+	"unicode/utf8"
+)
+
+// LinkifyOptions control which references in plain text become links in
+// HTML output (see HTMLOptions.Linkify). Text that is already a link (from
+// OSC 8 or an element) is left alone, so links are never nested.
+type LinkifyOptions struct {
+	// URLs turns bare http:// and https:// URLs into links.
+	URLs bool
+
+	// FileURL, if set, turns file references such as path/to/file.go:123 or
+	// path/to/file.go:123:4 into links. In the template, {path}, {line} and
+	// {col} are replaced with the parts of the reference, for example:
+	// "https://github.com/buildkite/terminal-to-html/blob/abc123/{path}#L{line}".
+	// Absolute paths aren't linked, since they are rarely in the repo.
+	FileURL string
+
+	// IssueURL, if set, turns issue references such as #123 into links, with
+	// {issue} in the template replaced by the number, for example:
+	// "https://github.com/buildkite/terminal-to-html/issues/{issue}".
+	IssueURL string
+}
+
+func (o LinkifyOptions) enabled() bool {
+	return o.URLs || o.FileURL != "" || o.IssueURL != ""
+}
+
+var (
+	// Bare URLs run until whitespace or a character that can't be in a URL.
+	// Trailing punctuation is trimmed by trimURL.
+	linkifyURLRE = regexp.MustCompile("https?://[^\\s<>\"'`]+")
+
+	// File references start at the start of the line or after whitespace or
+	// an opening bracket or quote, and need a file extension.
+	linkifyFileRE = regexp.MustCompile(`(?:^|[\s(\["'])((?:[\w.-]+/)*[\w-][\w.-]*\.\w+):(\d+)(?::(\d+))?\b`)
+
+	// Issue references start at the start of the line or after whitespace or
+	// an opening bracket.
+	linkifyIssueRE = regexp.MustCompile(`(?:^|[\s(\[])(#(\d+))\b`)
+)
+
+// linkify returns the parts of a line with the references that the options
+// ask for turned into hyperlinks. The parts are copied as needed, so the
+// screen isn't changed.
+func linkify(parts []screenLine, o LinkifyOptions) []screenLine {
+	// The text of the line, and for each byte of it, the node it came from.
+	// Elements and concealed text are replaced with NUL, so nothing can match
+	// them.
+	type nodeRef struct{ part, node int }
+	var text strings.Builder
+	var refs []nodeRef
+	for p, l := range parts {
+		for x, n := range l.nodes {
+			r := n.blob
+			switch {
+			case n.style.wideTail():
+				continue
+			case n.style.element(), n.style.conceal():
+				r = 0
+			case !utf8.ValidRune(r):
+				r = utf8.RuneError
+			}
+			text.WriteRune(r)
+			for range utf8.RuneLen(r) {
+				refs = append(refs, nodeRef{p, x})
+			}
+		}
+	}
+
+	// The parts (and then each part's nodes and hyperlinks) are copied before
+	// the first change to them.
+	out := parts
+	copied := false
+	cloned := make([]bool, len(parts))
+
+	// link links the text from byte start to byte end, unless some of it is
+	// already linked.
+	link := func(start, end int, target string) {
+		for _, ref := range refs[start:end] {
+			if n := out[ref.part].nodes[ref.node]; n.style.hyperlink() || n.style.element() {
+				return
+			}
+		}
+		for _, ref := range refs[start:end] {
+			if !cloned[ref.part] {
+				if !copied {
+					out = slices.Clone(parts)
+					copied = true
+				}
+				l := &out[ref.part]
+				l.nodes = slices.Clone(l.nodes)
+				l.hyperlinks = maps.Clone(l.hyperlinks)
+				if l.hyperlinks == nil {
+					l.hyperlinks = make(map[int]string)
+				}
+				cloned[ref.part] = true
+			}
+			l := &out[ref.part]
+			l.nodes[ref.node].style.setHyperlink(true)
+			l.hyperlinks[ref.node] = target
+		}
+	}
+
+	line := text.String()
+	if o.URLs {
+		for _, m := range linkifyURLRE.FindAllStringIndex(line, -1) {
+			u := trimURL(line[m[0]:m[1]])
+			link(m[0], m[0]+len(u), u)
+		}
+	}
+	if o.FileURL != "" {
+		for _, m := range linkifyFileRE.FindAllStringSubmatchIndex(line, -1) {
+			path, lineNum := line[m[2]:m[3]], line[m[4]:m[5]]
+			var col string
+			if m[6] >= 0 {
+				col = line[m[6]:m[7]]
+			}
+			end := m[5]
+			if m[7] >= 0 {
+				end = m[7]
+			}
+			target := strings.NewReplacer(
+				"{path}", escapePath(strings.TrimPrefix(path, "./")),
+				"{line}", lineNum,
+				"{col}", col,
+			).Replace(o.FileURL)
+			link(m[2], end, target)
+		}
+	}
+	if o.IssueURL != "" {
+		for _, m := range linkifyIssueRE.FindAllStringSubmatchIndex(line, -1) {
+			target := strings.ReplaceAll(o.IssueURL, "{issue}", line[m[4]:m[5]])
+			link(m[2], m[3], target)
+		}
+	}
+	return out
+}
+
+// trimURL removes punctuation from the end of a URL that is more likely to be
+// part of the surrounding text, such as a full stop or a closing bracket
+// without an opening bracket in the URL.
+func trimURL(u string) string {
+	for u != "" {
+		switch last := u[len(u)-1]; last {
+		case '.', ',', ':', ';', '!', '?':
+		case ')':
+			if strings.Count(u, "(") >= strings.Count(u, ")") {
+				return u
+			}
+		case ']':
+			if strings.Count(u, "[") >= strings.Count(u, "]") {
+				return u
+			}
+		default:
+			return u
+		}
+		u = u[:len(u)-1]
+	}
+	return u
+}
+
+// escapePath escapes each segment of a slash-separated path for use in a
+// URL.
+func escapePath(p string) string {
+	segments := strings.Split(p, "/")
+	for i, s := range segments {
+		segments[i] = url.PathEscape(s)
+	}
+	return strings.Join(segments, "/")
+}
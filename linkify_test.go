@@ -0,0 +1,113 @@
+package terminal
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestLinkify(t *testing.T) {
+	opts := LinkifyOptions{
+		URLs:     true,
+		FileURL:  "https://example.com/blob/abc/{path}#L{line}-{col}",
+		IssueURL: "https://example.com/issues/{issue}",
+	}
+
+	tests := []struct {
+		name  string
+		input string
+		opts  LinkifyOptions
+		want  string
+	}{
+		{
+			name:  "bare URL",
+			input: "see https://example.com/a?b=c&d=e for details",
+			opts:  opts,
+			want:  `see <a href="https://example.com/a?b=c&amp;d=e">https:&#47;&#47;example.com&#47;a?b=c&amp;d=e</a> for details`,
+		},
+		{
+			name:  "trailing punctuation is not part of the URL",
+			input: "(see https://example.com/wiki/Foo_(bar)). Or http://example.com/x.",
+			opts:  opts,
+			want:  `(see <a href="https://example.com/wiki/Foo_(bar)">https:&#47;&#47;example.com&#47;wiki&#47;Foo_(bar)</a>). Or <a href="http://example.com/x">http:&#47;&#47;example.com&#47;x</a>.`,
+		},
+		{
+			name:  "URLs only",
+			input: "main.go:12 #3 https://example.com",
+			opts:  LinkifyOptions{URLs: true},
+			want:  `main.go:12 #3 <a href="https://example.com">https:&#47;&#47;example.com</a>`,
+		},
+		{
+			name:  "file references",
+			input: "./cmd/main.go:12:4: undefined: x\n(in lib/a b.go:3)",
+			opts:  opts,
+			want: `<a href="https://example.com/blob/abc/cmd/main.go#L12-4">.&#47;cmd&#47;main.go:12:4</a>: undefined: x` + "\n" +
+				`(in lib&#47;a <a href="https://example.com/blob/abc/b.go#L3-">b.go:3</a>)`,
+		},
+		{
+			name:  "absolute paths and times are not file references",
+			input: "/usr/lib/x.go:12 at 12:30:45",
+			opts:  opts,
+			want:  `&#47;usr&#47;lib&#47;x.go:12 at 12:30:45`,
+		},
+		{
+			name:  "issue references",
+			input: "fixes #123 (#45), not a#6 or #7x",
+			opts:  opts,
+			want:  `fixes <a href="https://example.com/issues/123">#123</a> (<a href="https://example.com/issues/45">#45</a>), not a#6 or #7x`,
+		},
+		{
+			name:  "existing links are not nested",
+			input: "\x1b]8;;https://buildkite.com\x1b\\see https://example.com #1\x1b]8;;\x1b\\ https://example.com",
+			opts:  opts,
+			want:  `<a href="https://buildkite.com">see https:&#47;&#47;example.com #1</a> <a href="https://example.com">https:&#47;&#47;example.com</a>`,
+		},
+		{
+			name:  "styles are kept",
+			input: "\x1b[31merror at https://example.com\x1b[0m",
+			opts:  opts,
+			want:  `<span class="term-fg31">error at <a href="https://example.com">https:&#47;&#47;example.com</a></span>`,
+		},
+		{
+			name:  "unsafe templates are sanitised",
+			input: "#1",
+			opts:  LinkifyOptions{IssueURL: "javascript:alert({issue})"},
+			want:  `<a href="#">#1</a>`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			s, err := NewScreen()
+			if err != nil {
+				t.Fatalf("NewScreen() = %v", err)
+			}
+			s.Write([]byte(test.input))
+
+			got := s.AsHTMLWithOptions(HTMLOptions{Linkify: test.opts})
+			if diff := cmp.Diff(got, test.want); diff != "" {
+				t.Errorf("s.AsHTMLWithOptions(HTMLOptions{Linkify: %+v}) diff (-got +want):\n%s", test.opts, diff)
+			}
+		})
+	}
+}
+
+func TestLinkifyWrappedLine(t *testing.T) {
+	s, err := NewScreen(WithSize(10, 10))
+	if err != nil {
+		t.Fatalf("NewScreen() = %v", err)
+	}
+	s.Write([]byte("go to https://example.com/x now"))
+
+	// The link spans the screen lines the URL wrapped onto.
+	got := s.AsHTMLWithOptions(HTMLOptions{Linkify: LinkifyOptions{URLs: true}})
+	want := `go to <a href="https://example.com/x">http</a><a href="https://example.com/x">s:&#47;&#47;exampl</a><a href="https://example.com/x">e.com&#47;x</a> now`
+	if diff := cmp.Diff(got, want); diff != "" {
+		t.Errorf("s.AsHTMLWithOptions(HTMLOptions{Linkify: {URLs: true}}) diff (-got +want):\n%s", diff)
+	}
+
+	// The screen itself isn't changed.
+	if diff := cmp.Diff(s.AsHTML(), "go to https:&#47;&#47;example.com&#47;x now"); diff != "" {
+		t.Errorf("s.AsHTML() diff (-got +want):\n%s", diff)
+	}
+}
@@ -16,26 +16,85 @@ var (
 	))
 
 	openSpanTagTmpl = template.Must(template.New("span").Parse(
-		`<span class="{{.}}">`,
+		`<span{{with .Class}} class="{{.}}"{{end}}{{with .Style}} style="{{.}}"{{end}}>`,
 	))
 )
 
+// spanAttrs are the attributes for openSpanTagTmpl.
+type spanAttrs struct {
+	Class string
+	Style template.CSS
+}
+
+// HTMLOptions control how screen contents are rendered as HTML.
+type HTMLOptions struct {
+	// InlineStyles renders styles as inline CSS (style="...") using the
+	// colours from Theme, instead of as class names. The output can then be
+	// displayed without a stylesheet.
+	InlineStyles bool
+
+	// Theme provides colours for InlineStyles. If nil, BuildkiteTheme is used.
+	Theme *Theme
+
+	// Sections renders log groups as collapsible <details> blocks. Groups
+	// start at lines beginning with "--- " (collapsed), "+++ " (expanded) or
+	// "~~~ " (collapsed and de-emphasised), and run until the next group.
+	// GitHub Actions' ::group::title and ::endgroup:: lines are also
+	// supported.
+	Sections bool
+
+	// LineAnchors wraps each line in <span class="term-line" id="L<n>">, so
+	// that line n (counting from 1, including lines that have scrolled out)
+	// can be linked to as #L<n>.
+	LineAnchors bool
+
+	// LineNumbers adds a gutter of line numbers, each linking to its line.
+	// It implies LineAnchors.
+	LineNumbers bool
+
+	// Linkify turns URLs and other references in plain text into links.
+	Linkify LinkifyOptions
+
+	// URLPolicy controls which URLs can be linked to or embedded. If nil, any
+	// URL except javascript: URLs is allowed.
+	URLPolicy *URLPolicy
+
+	// Safe renders untrusted input as safe, well-formed HTML. Only span, a,
+	// img and time elements are used, so Sections and the alternate screen's
+	// <details> block are rendered as ordinary lines. As well as following
+	// URLPolicy, links must be http, https or mailto URLs (or relative), and
+	// images must be http or https URLs (or relative). Inline images must be
+	// PNG, JPEG, GIF or WebP, image sizes must be numbers of px, em or %, and
+	// control characters are dropped.
+	Safe bool
+}
+
 type outputBuffer struct {
 	strings.Builder
 }
 
-func (b *outputBuffer) appendNodeStyle(n node) {
-	openSpanTagTmpl.Execute(b, strings.Join(n.style.asClasses(), " "))
+func (b *outputBuffer) appendNodeStyle(n node, opts HTMLOptions) {
+	// The CSS declarations are all generated by us, so are safe to use as-is.
+	if opts.InlineStyles {
+		openSpanTagTmpl.Execute(b, spanAttrs{
+			Style: template.CSS(strings.Join(n.style.asInlineCSS(themeOrDefault(opts.Theme)), ";")),
+		})
+		return
+	}
+	openSpanTagTmpl.Execute(b, spanAttrs{
+		Class: strings.Join(n.style.asClasses(), " "),
+		Style: template.CSS(strings.Join(n.style.asCSS(), ";")),
+	})
 }
 
 func (b *outputBuffer) closeStyle() {
 	b.WriteString("</span>")
 }
 
-func (b *outputBuffer) appendAnchor(url string) {
+func (b *outputBuffer) appendAnchor(url string, opts HTMLOptions) {
 	b.WriteString(`<a href="`)
-	b.WriteString(html.EscapeString(sanitizeURL(url)))
-	b.WriteString(`">`)
+	b.WriteString(html.EscapeString(opts.url(url, URLLink)))
+	b.WriteString(`"` + opts.URLPolicy.linkAttrs() + `>`)
 }
 
 func (b *outputBuffer) closeAnchor() {
@@ -77,12 +136,91 @@ func (b *outputBuffer) appendChar(char rune) {
 	}
 }
 
+// htmlState is carried from one line of HTML to the next, so that lines
+// rendered one at a time (for ScrollOutFunc) come out the same as lines
+// rendered all together (by AsHTML).
+type htmlState struct {
+	// The number of lines rendered (or scrolled out unrendered) so far. The
+	// ::endgroup:: lines that sections swallow aren't counted, so that line
+	// numbers have no gaps.
+	lines int
+
+	// Whether a section's <details> tag is open.
+	sectionOpen bool
+}
+
+// line renders the parts of a line as HTML, continuing from the lines before
+// it.
+func (st *htmlState) line(parts []screenLine, opts HTMLOptions) string {
+	if opts.Sections && !opts.Safe {
+		return st.sectionLine(parts, opts)
+	}
+	return st.numberedLine(parts, opts)
+}
+
+// counts reports if the line is counted in st.lines when it is rendered.
+func (st *htmlState) counts(parts []screenLine, opts HTMLOptions) bool {
+	return !(opts.Sections && !opts.Safe && hasMarker(&parts[0], sectionEndGroupMarker))
+}
+
+// numberedLine renders the parts of a line with lineToHTML, wrapped in a span
+// with the line's anchor (and number) if the options ask for them. Any
+// <time> tag stays at the start of the line's contents, after the number.
+func (st *htmlState) numberedLine(parts []screenLine, opts HTMLOptions) string {
+	st.lines++
+	out := lineToHTML(parts, opts)
+	if !opts.LineAnchors && !opts.LineNumbers {
+		return out
+	}
+	n := strconv.Itoa(st.lines)
+	var buf strings.Builder
+	buf.WriteString(`<span class="term-line" id="L` + n + `">`)
+	if opts.LineNumbers {
+		buf.WriteString(`<a class="term-line-number" href="#L` + n + `">` + n + `</a>`)
+	}
+	buf.WriteString(strings.TrimSuffix(out, "\n"))
+	buf.WriteString("</span>\n")
+	return buf.String()
+}
+
+// linesToHTML renders screen lines as HTML, joining wrapped lines together.
+func linesToHTML(screen []screenLine, opts HTMLOptions) string {
+	var sb strings.Builder
+	for _, parts := range logicalLines(screen) {
+		sb.WriteString(lineToHTML(parts, opts))
+	}
+	return sb.String()
+}
+
+// logicalLines splits screen lines into groups that make up one line each
+// (a line, and the lines it wrapped onto).
+func logicalLines(screen []screenLine) [][]screenLine {
+	var lines [][]screenLine
+	for len(screen) > 0 {
+		// Find lineEnd of a line, or failing that, go to the end of the screen.
+		lineEnd := len(screen)
+		for i, l := range screen {
+			if l.newline {
+				lineEnd = i + 1
+				break
+			}
+		}
+		lines = append(lines, screen[:lineEnd])
+		screen = screen[lineEnd:]
+	}
+	return lines
+}
+
 // lineToHTML joins parts of a line together and renders them in HTML. It
 // ignores the newline field (i.e. assumes all parts are !newline except the
 // last part). The output string will have a terminating \n.
-func lineToHTML(parts []screenLine) string {
+func lineToHTML(parts []screenLine, opts HTMLOptions) string {
 	var buf outputBuffer
 
+	if opts.Linkify.enabled() {
+		parts = linkify(parts, opts.Linkify)
+	}
+
 	// Combine metadata - last metadata wins.
 	bkmd := make(map[string]string)
 	for _, l := range parts {
@@ -120,6 +258,12 @@ func lineToHTML(parts []screenLine) string {
 
 	for _, l := range parts {
 		for x, current := range l.nodes {
+			if current.style.wideTail() && !current.style.conceal() {
+				// The first column of the wide character was already written.
+				// (Concealed text is replaced with spaces, one per column.)
+				continue
+			}
+
 			// A set of flags for which tags need changing.
 			tagChanged := []bool{
 				// The anchor tag needs changing if the link "style" has changed,
@@ -150,21 +294,30 @@ func lineToHTML(parts []screenLine) string {
 			// Open a new anchor tag, if one is not already open and this node is
 			// hyperlinked.
 			if !slices.Contains(tagStack, tagAnchor) && current.style.hyperlink() {
-				buf.appendAnchor(l.hyperlinks[x])
+				buf.appendAnchor(l.hyperlinks[x], opts)
 				tagStack = append(tagStack, tagAnchor)
 			}
 			// Open a new span tag, if one is not already open and this node has
 			// style.
 			if !slices.Contains(tagStack, tagSpan) && !current.style.isPlain() {
-				buf.appendNodeStyle(current)
+				buf.appendNodeStyle(current, opts)
 				tagStack = append(tagStack, tagSpan)
 			}
 
-			// Write a standalone element or a rune.
-			if current.style.element() {
-				buf.WriteString(l.elements[current.blob].asHTML())
-			} else {
+			// Write a standalone element or a rune. Concealed text is replaced
+			// with spaces.
+			switch {
+			case current.style.element():
+				buf.WriteString(l.elements[current.blob].asHTML(opts))
+			case current.style.conceal():
+				buf.appendChar(' ')
+			case opts.Safe && isControl(current.blob):
+				// Dropped.
+			default:
 				buf.appendChar(current.blob)
+				for _, r := range l.combining[x] {
+					buf.appendChar(r)
+				}
 			}
 
 			previous = current
@@ -186,9 +339,24 @@ func lineToHTML(parts []screenLine) string {
 func (l *screenLine) asPlain() string {
 	var buf strings.Builder
 
-	for _, node := range l.nodes {
-		if !node.style.element() {
+	for x, node := range l.nodes {
+		switch {
+		case node.style.element():
+			// Other elements have no text, but the alternate screen does.
+			if elem := l.elements[node.blob]; elem.elementType == elementAltScreen {
+				var alt strings.Builder
+				for _, line := range elem.lines {
+					alt.WriteString(line.asPlain())
+				}
+				buf.WriteString(strings.TrimSuffix(alt.String(), "\n"))
+			}
+		case node.style.conceal():
+			buf.WriteRune(' ')
+		case node.style.wideTail():
+			// already written with the first column
+		default:
 			buf.WriteRune(node.blob)
+			buf.WriteString(l.combining[x])
 		}
 	}
 
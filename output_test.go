@@ -1,6 +1,7 @@
 package terminal
 
 import (
+	"strings"
 	"testing"
 
 	"github.com/google/go-cmp/cmp"
@@ -38,10 +39,256 @@ func TestScreenLineAsHTML_Interleaving(t *testing.T) {
 				t.Fatalf("len(s.screen) = %d, want 1", len(s.screen))
 			}
 
-			got := lineToHTML(s.screen[:1])
+			got := lineToHTML(s.screen[:1], HTMLOptions{})
 			if diff := cmp.Diff(got, test.want); diff != "" {
-				t.Errorf("lineToHTML(s.screen[:1]) diff (-got +want):\n%s", diff)
+				t.Errorf("lineToHTML(s.screen[:1], HTMLOptions{}) diff (-got +want):\n%s", diff)
 			}
 		})
 	}
 }
+
+func TestScreenAsHTMLWithInlineStyles(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{
+			name:  "SGR colors",
+			input: "\x1b[31mred\x1b[0m \x1b[94;42mblue on green",
+			want:  `<span style="color:#ff7070">red</span> <span style="color:#6871ff;background-color:#b0f986">blue on green</span>`,
+		},
+		{
+			name:  "xterm standard colors use the theme",
+			input: "\x1b[38;5;1mred\x1b[48;5;9mbright red",
+			want:  `<span style="color:#ff7070">red</span><span style="color:#ff7070;background-color:#ff3333">bright red</span>`,
+		},
+		{
+			name:  "xterm color cube",
+			input: "\x1b[38;5;169;48;5;50mhello",
+			want:  `<span style="color:#d75faf;background-color:#00ffd7">hello</span>`,
+		},
+		{
+			name:  "xterm greyscale ramp",
+			input: "\x1b[38;5;232mdark\x1b[38;5;255mlight",
+			want:  `<span style="color:#080808">dark</span><span style="color:#eeeeee">light</span>`,
+		},
+		{
+			name:  "24-bit colors",
+			input: "\x1b[38;2;1;2;3;48;2;255;128;0mhello",
+			want:  `<span style="color:#010203;background-color:#ff8000">hello</span>`,
+		},
+		{
+			name:  "faint only applies without a foreground color",
+			input: "\x1b[2mfaint \x1b[32mgreen",
+			want:  `<span style="color:#838887">faint </span><span style="color:#b0f986">green</span>`,
+		},
+		{
+			name:  "reverse video swaps default colors",
+			input: "\x1b[7mreversed\x1b[0m \x1b[7;34mblue bg",
+			want:  `<span style="color:#171717;background-color:#ffffff">reversed</span> <span style="color:#171717;background-color:#8db7e0">blue bg</span>`,
+		},
+		{
+			name:  "attributes",
+			input: "\x1b[3mitalic\x1b[0m \x1b[4;9mboth\x1b[0m \x1b[9mstrike",
+			want:  `<span style="font-style:italic">italic</span> <span style="text-decoration:underline line-through">both</span> <span style="text-decoration:line-through">strike</span>`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			s, err := NewScreen()
+			if err != nil {
+				t.Fatalf("NewScreen() = %v", err)
+			}
+			s.Write([]byte(test.input))
+
+			got := s.AsHTMLWithOptions(HTMLOptions{InlineStyles: true})
+			if diff := cmp.Diff(got, test.want); diff != "" {
+				t.Errorf("s.AsHTMLWithOptions(HTMLOptions{InlineStyles: true}) diff (-got +want):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestScreenAsHTMLWithLineAnchors(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		opts  HTMLOptions
+		want  string
+	}{
+		{
+			name:  "anchors",
+			input: "one\n\nthree",
+			opts:  HTMLOptions{LineAnchors: true},
+			want: `<span class="term-line" id="L1">one</span>
+<span class="term-line" id="L2">&nbsp;</span>
+<span class="term-line" id="L3">three</span>`,
+		},
+		{
+			name:  "line numbers",
+			input: "one\ntwo",
+			opts:  HTMLOptions{LineNumbers: true},
+			want: `<span class="term-line" id="L1"><a class="term-line-number" href="#L1">1</a>one</span>
+<span class="term-line" id="L2"><a class="term-line-number" href="#L2">2</a>two</span>`,
+		},
+		{
+			name:  "timestamps stay at the start of the line",
+			input: "\x1b_bk;t=1700000000000\x07\x1b[31mred",
+			opts:  HTMLOptions{LineNumbers: true},
+			want:  `<span class="term-line" id="L1"><a class="term-line-number" href="#L1">1</a><time datetime="2023-11-14T22:13:20Z">2023-11-14T22:13:20Z</time><span class="term-fg31">red</span></span>`,
+		},
+		{
+			name:  "sections",
+			input: "--- group\ninside\n::endgroup::\nafter",
+			opts:  HTMLOptions{LineAnchors: true, Sections: true},
+			want: `<details class="term-section"><summary><span class="term-line" id="L1">group</span></summary><span class="term-line" id="L2">inside</span>
+</details><span class="term-line" id="L3">after</span>`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			s, err := NewScreen(WithSize(20, 10))
+			if err != nil {
+				t.Fatalf("NewScreen() = %v", err)
+			}
+			s.Write([]byte(test.input))
+
+			got := s.AsHTMLWithOptions(test.opts)
+			if diff := cmp.Diff(got, test.want); diff != "" {
+				t.Errorf("s.AsHTMLWithOptions(%+v) diff (-got +want):\n%s", test.opts, diff)
+			}
+		})
+	}
+}
+
+func TestLineAnchorsWrappedLine(t *testing.T) {
+	s, err := NewScreen(WithSize(4, 10))
+	if err != nil {
+		t.Fatalf("NewScreen() = %v", err)
+	}
+	s.Write([]byte("abcdefgh\nnext"))
+
+	got := s.AsHTMLWithOptions(HTMLOptions{LineAnchors: true})
+	want := `<span class="term-line" id="L1">abcdefgh</span>
+<span class="term-line" id="L2">next</span>`
+	if diff := cmp.Diff(got, want); diff != "" {
+		t.Errorf("s.AsHTMLWithOptions(HTMLOptions{LineAnchors: true}) diff (-got +want):\n%s", diff)
+	}
+}
+
+func TestLineAnchorsScrollOut(t *testing.T) {
+	opts := HTMLOptions{LineAnchors: true}
+	tests := []struct {
+		name          string
+		format        Format
+		scrollOutFunc bool
+	}{
+		{name: "streamed as HTML", format: FormatHTML, scrollOutFunc: true},
+		{name: "streamed as JSON", format: FormatJSON, scrollOutFunc: true},
+		{name: "dropped", format: FormatHTML, scrollOutFunc: false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			s, err := NewScreen(WithMaxSize(0, 2), WithHTMLOptions(opts), WithFormat(test.format))
+			if err != nil {
+				t.Fatalf("NewScreen() = %v", err)
+			}
+			var scrolledOut strings.Builder
+			if test.scrollOutFunc {
+				s.ScrollOutFunc = func(line string) { scrolledOut.WriteString(line) }
+			}
+			s.Write([]byte("one\ntwo\nthree\nfour"))
+
+			if test.format == FormatHTML && test.scrollOutFunc {
+				want := `<span class="term-line" id="L1">one</span>
+<span class="term-line" id="L2">two</span>
+`
+				if diff := cmp.Diff(scrolledOut.String(), want); diff != "" {
+					t.Errorf("scrolled out diff (-got +want):\n%s", diff)
+				}
+			}
+
+			want := `<span class="term-line" id="L3">three</span>
+<span class="term-line" id="L4">four</span>`
+			if diff := cmp.Diff(s.AsHTML(), want); diff != "" {
+				t.Errorf("s.AsHTML() diff (-got +want):\n%s", diff)
+			}
+			if got, want := s.LinesScrolledOut, 2; got != want {
+				t.Errorf("s.LinesScrolledOut = %d, want %d", got, want)
+			}
+		})
+	}
+}
+
+func TestScreenAsHTMLSafe(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{
+			name:  "links need safe schemes",
+			input: "\x1b]8;;data:text/html,hi\x1b\\a\x1b]8;;\x1b\\ \x1b]8;;mailto:a@example.com\x1b\\b\x1b]8;;\x1b\\ \x1b]1339;url=ftp://example.com;content=c\x07",
+			want:  `<a href="#">a</a> <a href="mailto:a@example.com">b</a> <a href="#">c</a>`,
+		},
+		{
+			name:  "images need safe schemes",
+			input: "\x1b]1338;url=ftp://example.com/a.png\x07\x1b]1338;url=/a.png\x07",
+			want:  "&nbsp;\n" + `<img alt="/a.png" src="/a.png">`,
+		},
+		{
+			name:  "inline SVG images are dropped",
+			input: "\x1b]1337;File=name=YS5zdmc=;inline=1:PHN2Zz48L3N2Zz4=\x07",
+			want:  "&nbsp;",
+		},
+		{
+			name:  "invalid image sizes are dropped",
+			input: "\x1b]1337;File=name=YS5wbmc=;inline=1;width=10\\\";height=5px:AAAA\x07",
+			want:  `<img alt="a.png" src="data:image/png;base64,AAAA" height="5px">`,
+		},
+		{
+			name:  "no sections",
+			input: "--- group\ninside",
+			want:  "--- group\ninside",
+		},
+		{
+			name:  "control characters are dropped",
+			input: "a\x00b",
+			want:  "ab",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			s, err := NewScreen()
+			if err != nil {
+				t.Fatalf("NewScreen() = %v", err)
+			}
+			s.Write([]byte(test.input))
+
+			got := s.AsHTMLWithOptions(HTMLOptions{Safe: true, Sections: true})
+			if diff := cmp.Diff(got, test.want); diff != "" {
+				t.Errorf("s.AsHTMLWithOptions(HTMLOptions{Safe: true, Sections: true}) diff (-got +want):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestScreenAsHTMLSafeAltScreen(t *testing.T) {
+	s, err := NewScreen(WithSize(20, 3), WithAltScreenPolicy(AltScreenInline))
+	if err != nil {
+		t.Fatalf("NewScreen() = %v", err)
+	}
+	s.Write([]byte("before\n\x1b[?1049hvim\x1b[?1049lafter"))
+
+	if got := s.AsHTMLWithOptions(HTMLOptions{Safe: true}); strings.Contains(got, "<details") {
+		t.Errorf("s.AsHTMLWithOptions(HTMLOptions{Safe: true}) = %q, want no <details>", got)
+	}
+	if got := s.AsHTML(); !strings.Contains(got, "<details") {
+		t.Errorf("s.AsHTML() = %q, want <details>", got)
+	}
+}
@@ -1,7 +1,7 @@
 package terminal
 
 import (
-	"unicode"
+	"fmt"
 	"unicode/utf8"
 )
 
@@ -13,11 +13,20 @@ const (
 	parserModeOSCEsc // within OSC and just read an escape
 	parserModeCharset
 	parserModeAPC
-	parserModeAPCEsc // within APC and just read an escape
+	parserModeAPCEsc  // within APC and just read an escape
+	parserModeSkip    // within an OSC or APC that is too long to buffer
+	parserModeSkipEsc // within a skipped sequence and just read an escape
 )
 
-type position struct {
-	x, y int
+// cursorState is the state saved by DECSC (ESC 7 or CSI s) and restored by
+// DECRC (ESC 8 or CSI u).
+type cursorState struct {
+	x, y       int
+	style      style
+	urlBrush   string
+	charsets   [2]rune
+	shiftOut   bool
+	originMode bool
 }
 
 // Stateful ANSI parser
@@ -29,8 +38,15 @@ type parser struct {
 	cursor               int
 	escapeStartedAt      int
 	instructions         []string
+	params               []string // all of the instructions, including empty ones
 	instructionStartedAt int
-	savePosition         position
+	savedCursor          cursorState
+
+	// Character set state. charsets holds the designators for G0 and G1,
+	// and shiftOut is true when G1 is in use (after SO, until SI).
+	charsets      [2]rune
+	charsetTarget int
+	shiftOut      bool
 
 	// Buildkite-specific state
 	lastTimestamp int64
@@ -54,8 +70,8 @@ type parser struct {
  * 2. For `]` we enter parserModeOSC and look for an operating system command.
  * 3. For `(` or ')' we enter parserModeCharset and look for a character set name.
  * 4. For `_` we enter parserModeAPC and parse the rest of the custom control sequence
- * 5. For `M`, `7`, or `8`, we run an instruction directly (reverse newline,
- *    or save/restore cursor).
+ * 5. For `M`, `H`, `7`, or `8`, we run an instruction directly (reverse newline,
+ *    set tab stop, or save/restore cursor).
  *
  * In all cases we start our instruction buffer. The instruction buffer is used
  * to store the individual characters that make up ANSI instructions before
@@ -78,8 +94,13 @@ type parser struct {
  * parserModeAPC is just like parserModeOSC, except the contents should be processed
  * differently.
  *
- * If we're in parserModeCharset we simply discard the next character which would
- * normally designate the character set.
+ * If an OSC or APC gets longer than Limits.MaxSequenceBytes, we write a placeholder
+ * instead and enter parserModeSkip, which drops everything up to the terminator
+ * without buffering it.
+ *
+ * If we're in parserModeCharset the next character designates the character set
+ * for G0 (after `(`) or G1 (after `)`). The SO and SI control characters switch
+ * between G0 and G1.
  */
 
 func (p *parser) parseToScreen(input []byte) {
@@ -121,6 +142,11 @@ func (p *parser) parseToScreen(input []byte) {
 			// We're inside an APC, and just hit an ESC (which might be ST)
 			p.handleAPCEscape(char)
 
+		case parserModeSkip, parserModeSkipEsc:
+			// We're inside an OSC or APC that is over the length limit, skip
+			// until we hit BEL or ESC \ (ST)
+			p.handleSkip(char)
+
 		case parserModeNormal:
 			// Outside of an escape sequence entirely, normal input
 			p.handleNormal(char)
@@ -130,7 +156,8 @@ func (p *parser) parseToScreen(input []byte) {
 	}
 
 	// If we're in normal mode, everything up to the cursor has been procesed.
-	if p.mode == parserModeNormal {
+	// The same goes for skipping a sequence, which isn't buffered.
+	if p.mode == parserModeNormal || p.mode == parserModeSkip || p.mode == parserModeSkipEsc {
 		p.cursor = 0
 		p.remainder = p.remainder[:0]
 		return
@@ -149,8 +176,9 @@ func (p *parser) parseToScreen(input []byte) {
 }
 
 // handleCharset is called for each character consumed while in parserModeCharset.
-// It ignores the character and transitions back to parserModeNormal.
-func (p *parser) handleCharset(rune) {
+// It designates the character set and transitions back to parserModeNormal.
+func (p *parser) handleCharset(char rune) {
+	p.charsets[p.charsetTarget] = char
 	p.mode = parserModeNormal
 }
 
@@ -183,6 +211,7 @@ func (p *parser) handleOperatingSystemCommand(char rune) {
 
 	default:
 		// OSC continues...
+		p.checkSequenceLength()
 	}
 }
 
@@ -223,7 +252,16 @@ func (p *parser) processOperatingSystemCommand(end int) {
 		return
 	}
 
-	p.screen.appendElement(element)
+	element = p.screen.limitElement(element)
+	if element != nil && element.elementType == elementITermImage && p.screen.imageSink != nil {
+		if element, err = p.screen.storeImage(element); err != nil {
+			p.screen.appendMany([]rune("*** Error storing image: "))
+			p.screen.appendMany([]rune(err.Error()))
+		}
+	}
+	if element != nil {
+		p.screen.appendElement(element)
+	}
 
 	if ownLine {
 		p.screen.newLine()
@@ -274,6 +312,35 @@ func (p *parser) handleApplicationProgramCommand(char rune) {
 
 	default:
 		// APC continues...
+		p.checkSequenceLength()
+	}
+}
+
+// checkSequenceLength is called while reading an OSC or APC. If the sequence
+// is over the length limit, a placeholder is written in its place, and the
+// rest of it is skipped.
+func (p *parser) checkSequenceLength() {
+	limit := p.screen.limits.MaxSequenceBytes
+	if limit <= 0 || p.cursor-p.instructionStartedAt < limit {
+		return
+	}
+	p.mode = parserModeSkip
+	p.screen.appendMany([]rune(fmt.Sprintf("*** Escape sequence omitted: over the limit of %d bytes", limit)))
+}
+
+// handleSkip is called for each character consumed while skipping a sequence
+// that is too long. It does nothing until the sequence is terminated with
+// either BEL or ESC \ (ST).
+func (p *parser) handleSkip(char rune) {
+	switch {
+	case char == '\x07':
+		p.mode = parserModeNormal
+	case char == '\x1b':
+		p.mode = parserModeSkipEsc
+	case p.mode == parserModeSkipEsc && char == '\\':
+		p.mode = parserModeNormal
+	default:
+		p.mode = parserModeSkip
 	}
 }
 
@@ -299,24 +366,69 @@ func (p *parser) processApplicationProgramCommand(end int) {
 // handleControlSequence is called for each character consumed while in
 // parserModeControl.
 func (p *parser) handleControlSequence(char rune) {
-	char = unicode.ToUpper(char)
+	// Some sequences are equivalent to others.
 	switch char {
-	case '?', '0', '1', '2', '3', '4', '5', '6', '7', '8', '9':
-		// Part of an instruction
+	case 'a': // Horizontal Position Relative
+		char = 'C'
+	case 'e': // Vertical Position Relative
+		char = 'B'
+	case 'f': // Horizontal Vertical Position
+		char = 'H'
+	}
+
+	// The final character is case-sensitive: e.g. CSI M (delete line) and
+	// CSI m (SGR) are unrelated.
+	switch char {
+	case '?', ':', '0', '1', '2', '3', '4', '5', '6', '7', '8', '9':
+		// Part of an instruction (':' separates sub-parameters, e.g. 38:2::r:g:b)
 
 	case ';':
 		p.addInstruction()
 		p.instructionStartedAt = p.cursor + utf8.RuneLen(';')
 
-	case 'A', 'B', 'C', 'D', 'E', 'F', 'G', 'H', 'J', 'K', 'M', 'Q':
+	case 'A', 'B', 'C', 'D', 'E', 'F', 'G', 'H', 'I', 'J', 'K', 'L', 'M', 'P', 'S', 'T', 'X', 'Z', '@', 'd', 'g', 'm':
 		p.addInstruction()
 		p.screen.applyEscape(char, p.instructions)
 		p.mode = parserModeNormal
 
-	case 'I', 'L', 'N':
+	case 't':
+		// Window manipulation (XTWINOPS). Omitted parameters mean something
+		// here (CSI 8;;80t only changes the width), so use p.params.
+		p.addInstruction()
+		p.screen.windowOp(p.params)
+		p.mode = parserModeNormal
+
+	case 'h', 'l':
+		// Set/reset mode (SM/RM, and DECSET/DECRST with a ? prefix)
+		p.addInstruction()
+		p.screen.setMode(char == 'h', p.instructions)
+		p.mode = parserModeNormal
+
+	case 'r':
+		// Set scroll region (DECSTBM)
+		p.addInstruction()
+		p.screen.setScrollRegion(p.instructions)
+		p.mode = parserModeNormal
+
+	case 's', 'u':
+		// SCOSC and SCORC: the same as DECSC and DECRC (ESC 7 and ESC 8).
+		// With parameters, these mean other things (e.g. setting margins, or
+		// keyboard protocol queries), which aren't relevant.
+		p.addInstruction()
+		if len(p.instructions) == 0 {
+			if char == 's' {
+				p.saveCursor()
+			} else {
+				p.restoreCursor()
+			}
+		}
+		p.mode = parserModeNormal
+
+	case 'c', 'i', 'n', 'q':
+		// CSI c: Device attributes
 		// CSI i: Enable/disable AUX port
-		// CSI L: Set/reset mode (SM/RM)
 		// CSI n: Report cursor position
+		// CSI q: Load LEDs
 		// All not relevant to us. Swallow the code and continue
 		p.mode = parserModeNormal
 
@@ -336,11 +448,17 @@ func (p *parser) handleNormal(char rune) {
 		p.screen.carriageReturn()
 	case '\b':
 		p.screen.backspace()
+	case '\t':
+		p.screen.tabForward(1)
+	case '\x0e': // SO: use G1
+		p.shiftOut = true
+	case '\x0f': // SI: use G0
+		p.shiftOut = false
 	case '\x1b':
 		p.escapeStartedAt = p.cursor
 		p.mode = parserModeEscape
 	default:
-		p.screen.append(char)
+		p.screen.append(p.translate(char))
 	}
 }
 
@@ -350,6 +468,7 @@ func (p *parser) handleEscape(char rune) {
 	case '[':
 		p.instructionStartedAt = p.cursor + utf8.RuneLen('[')
 		p.instructions = make([]string, 0, 1)
+		p.params = p.params[:0]
 		p.mode = parserModeControl
 
 	case ']':
@@ -358,6 +477,10 @@ func (p *parser) handleEscape(char rune) {
 
 	case ')', '(':
 		p.instructionStartedAt = p.cursor + utf8.RuneLen('(')
+		p.charsetTarget = 0
+		if char == ')' {
+			p.charsetTarget = 1
+		}
 		p.mode = parserModeCharset
 
 	case '_':
@@ -368,13 +491,16 @@ func (p *parser) handleEscape(char rune) {
 		p.screen.revNewLine()
 		p.mode = parserModeNormal
 
-	case '7':
-		p.savePosition = position{x: p.screen.x, y: p.screen.y}
+	case 'H': // HTS
+		p.screen.setTabStop(min(p.screen.x, p.screen.cols-1))
+		p.mode = parserModeNormal
+
+	case '7': // DECSC
+		p.saveCursor()
 		p.mode = parserModeNormal
 
-	case '8':
-		p.screen.x = p.savePosition.x
-		p.screen.y = p.savePosition.y
+	case '8': // DECRC
+		p.restoreCursor()
 		p.mode = parserModeNormal
 
 	case '=', '>': // DECKPAM, DECKPNM
@@ -391,10 +517,42 @@ func (p *parser) handleEscape(char rune) {
 	}
 }
 
+// saveCursor saves the cursor position, along with the style, link and
+// character set in use.
+func (p *parser) saveCursor() {
+	p.savedCursor = cursorState{
+		x:          p.screen.x,
+		y:          p.screen.y,
+		style:      p.screen.style,
+		urlBrush:   p.screen.urlBrush,
+		charsets:   p.charsets,
+		shiftOut:   p.shiftOut,
+		originMode: p.screen.originMode,
+	}
+}
+
+// restoreCursor restores the state saved by saveCursor.
+func (p *parser) restoreCursor() {
+	sc := p.savedCursor
+	// The window might have been resized since, so keep the cursor within
+	// it. (The cursor can be just past the end of a line, or just below the
+	// window, in the same way it can be after writing a character or a
+	// newline.)
+	p.screen.x = min(sc.x, p.screen.cols)
+	p.screen.y = min(sc.y, p.screen.lines)
+	p.screen.style = sc.style
+	p.screen.urlBrush = sc.urlBrush
+	p.charsets = sc.charsets
+	p.shiftOut = sc.shiftOut
+	p.screen.originMode = sc.originMode
+}
+
 // addInstruction appends an instruction to p.instructions, if the current
-// instruction is nonempty.
+// instruction is nonempty. p.params gets every instruction, so that omitted
+// parameters keep their position.
 func (p *parser) addInstruction() {
 	instruction := string(p.buffer.slice(p.instructionStartedAt, p.cursor))
+	p.params = append(p.params, instruction)
 	if instruction != "" {
 		p.instructions = append(p.instructions, instruction)
 	}
@@ -91,6 +91,17 @@ func TestParseDECCursorSaveRestore(t *testing.T) {
 
 // ----------------------------------------
 
+func TestParseRestoreCursorAfterResize(t *testing.T) {
+	s := parsedScreen(t, "hello\n\n\n\x1b[10C\x1b7")
+	if err := s.SetSize(5, 2); err != nil {
+		t.Fatalf("s.SetSize(5, 2) error = %v", err)
+	}
+	s.Write([]byte("\x1b8"))
+	if err := assertXY(s, 5, 2); err != nil {
+		t.Error(err)
+	}
+}
+
 func parsedScreen(t *testing.T, data string) *Screen {
 	s, err := NewScreen()
 	if err != nil {
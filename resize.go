@@ -0,0 +1,238 @@
+package terminal
+
+import (
+	"maps"
+	"slices"
+	"strconv"
+)
+
+// Without a maximum size (see WithMaxSize), sizes set by the stream are
+// limited to these, so that input can't make the screen allocate huge lines.
+const (
+	defaultMaxStreamColumns = 400
+	defaultMaxStreamLines   = 1000
+)
+
+// streamMaxSize returns the largest size that the stream can set: the maximum
+// size, or the default limits if there isn't one.
+func (s *Screen) streamMaxSize() (cols, lines int) {
+	cols, lines = s.maxColumns, s.maxLines
+	if cols <= 0 {
+		cols = defaultMaxStreamColumns
+	}
+	if lines <= 0 {
+		lines = defaultMaxStreamLines
+	}
+	return cols, lines
+}
+
+// windowOp handles window manipulation (XTWINOPS). Only resizing is
+// supported: CSI 8 ; lines ; cols t, where an omitted size keeps the current
+// one. params includes empty parameters.
+func (s *Screen) windowOp(params []string) {
+	if len(params) == 0 || params[0] != "8" {
+		return
+	}
+	param := func(i int) int {
+		if i >= len(params) {
+			return 0
+		}
+		n, _ := strconv.Atoi(params[i])
+		return n
+	}
+	s.resize(param(2), param(1))
+}
+
+// resize handles a resize signal in the stream, such as XTWINOPS (CSI 8 ; h ;
+// w t). Unlike SetSize, the new size is limited to the maximum size (or the
+// default limits) rather than rejected, and 0 (or an omitted size) keeps the
+// current size.
+func (s *Screen) resize(cols, lines int) {
+	maxCols, maxLines := s.streamMaxSize()
+	if cols <= 0 {
+		cols = s.cols
+	} else {
+		cols = min(cols, maxCols)
+	}
+	if lines <= 0 {
+		lines = s.lines
+	} else {
+		lines = min(lines, maxLines)
+	}
+	// Since the size is within limits, this can't fail.
+	_ = s.SetSize(cols, lines)
+}
+
+// reflow rewraps the soft-wrapped lines in the window to a new width, the way
+// modern terminals do, and moves the cursor along with the text it was on.
+// cursor is the index within the buffer of the line the cursor is on, and the
+// new index is returned.
+// Lines entirely above the window (in the scrollback) are left alone.
+func (s *Screen) reflow(cols, cursor int) int {
+	// Start from the beginning of the line that continues into the top of the
+	// window, if any.
+	start := s.top()
+	for start > 0 && !s.screen[start-1].newline {
+		start--
+	}
+	if start >= len(s.screen) {
+		return cursor
+	}
+
+	reflowed := slices.Clone(s.screen[:start])
+	newCursor := cursor
+	lines := s.screen[start:]
+	for len(lines) > 0 {
+		// Find the end of the (possibly wrapped) line.
+		end := len(lines)
+		for i, l := range lines {
+			if l.newline {
+				end = i + 1
+				break
+			}
+		}
+		parts := lines[:end]
+		lines = lines[end:]
+
+		// Where is the cursor within this line, if it's on this line?
+		offset := -1
+		if idx := cursor - (len(s.screen) - len(lines) - end); idx >= 0 && idx < end {
+			offset = s.x
+			for _, p := range parts[:idx] {
+				offset += len(p.nodes)
+			}
+		}
+
+		rewrapped, row, x := rewrap(parts, cols, offset)
+		if offset >= 0 {
+			newCursor = len(reflowed) + row
+			s.x = x
+		}
+		reflowed = append(reflowed, rewrapped...)
+	}
+
+	// The old node slices can be recycled.
+	for _, l := range s.screen[start:] {
+		if cap(l.nodes) > 0 {
+			s.nodeRecycling = append(s.nodeRecycling, l.nodes[:0])
+		}
+	}
+	if cursor >= len(s.screen) {
+		// The cursor is below the end of the buffer, so it stays the same
+		// distance from the end.
+		newCursor = cursor - len(s.screen) + len(reflowed)
+	}
+	s.screen = reflowed
+	return newCursor
+}
+
+// rewrap joins the parts of a wrapped line together, and splits it again at
+// a new width. If offset is not negative, it is the position of the cursor
+// within the whole line, and rewrap also returns the row and column it ends
+// up at within the new parts.
+func rewrap(parts []screenLine, cols, offset int) (lines []screenLine, row, x int) {
+	var (
+		nodes      []node
+		elements   []*element
+		hyperlinks map[int]string
+		combining  map[int]string
+		metadata   map[string]map[string]string
+	)
+	for _, p := range parts {
+		// Elements are referred to by index, so renumber them.
+		for _, n := range p.nodes {
+			if n.style.element() {
+				n.blob += rune(len(elements))
+			}
+			nodes = append(nodes, n)
+		}
+		elements = append(elements, p.elements...)
+		hyperlinks = addKeys(hyperlinks, p.hyperlinks, len(nodes)-len(p.nodes))
+		combining = addKeys(combining, p.combining, len(nodes)-len(p.nodes))
+
+		// Metadata is combined for the whole line when rendering, so combine
+		// it now in the same way (last metadata wins).
+		for ns, md := range p.metadata {
+			if metadata == nil {
+				metadata = make(map[string]map[string]string)
+			}
+			if metadata[ns] == nil {
+				metadata[ns] = make(map[string]string)
+			}
+			maps.Copy(metadata[ns], md)
+		}
+	}
+
+	// Split the nodes into lines no wider than cols, without splitting wide
+	// characters.
+	for start := 0; ; {
+		end := min(start+cols, len(nodes))
+		if end < len(nodes) && nodes[end].style.wideTail() && end-1 > start {
+			end--
+		}
+		l := screenLine{
+			nodes:      slices.Clone(nodes[start:end]),
+			hyperlinks: subKeys(hyperlinks, start, end),
+			combining:  subKeys(combining, start, end),
+		}
+		// Each line gets only the elements its nodes refer to, so renumber
+		// them again.
+		for i, n := range l.nodes {
+			if n.style.element() {
+				l.nodes[i].blob = rune(len(l.elements))
+				l.elements = append(l.elements, elements[n.blob])
+			}
+		}
+		if start == 0 {
+			l.metadata = metadata
+		}
+		lines = append(lines, l)
+
+		if offset >= start && (offset < end || end == len(nodes)) {
+			// The cursor can end up past the end of the last line, but
+			// no further than just past the last column (as happens after
+			// writing to the last column).
+			row, x = len(lines)-1, min(offset-start, cols)
+		}
+		start = end
+		if start >= len(nodes) {
+			break
+		}
+	}
+
+	// Only the last line ends with a newline (unless the last part of the
+	// old line didn't either, which happens at the end of the buffer).
+	lines[len(lines)-1].newline = parts[len(parts)-1].newline
+	return lines, row, x
+}
+
+// addKeys copies the entries from src into dst, with keys increased by n.
+// dst is created if needed.
+func addKeys[V any](dst, src map[int]V, n int) map[int]V {
+	if len(src) == 0 {
+		return dst
+	}
+	if dst == nil {
+		dst = make(map[int]V, len(src))
+	}
+	for k, v := range src {
+		dst[k+n] = v
+	}
+	return dst
+}
+
+// subKeys returns the entries from m with keys in the range [start, end),
+// with keys decreased by start, or nil if there are none.
+func subKeys[V any](m map[int]V, start, end int) map[int]V {
+	var sub map[int]V
+	for k, v := range m {
+		if k < start || k >= end {
+			continue
+		}
+		if sub == nil {
+			sub = make(map[int]V)
+		}
+		sub[k-start] = v
+	}
+	return sub
+}
@@ -0,0 +1,229 @@
+package terminal
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+// lineWidths returns the number of nodes in each line of the buffer.
+func lineWidths(s *Screen) []int {
+	var widths []int
+	for _, l := range s.screen {
+		widths = append(widths, len(l.nodes))
+	}
+	return widths
+}
+
+func TestSetSizeReflow(t *testing.T) {
+	tests := []struct {
+		name       string
+		cols       int
+		input      string
+		newCols    int
+		after      string
+		wantWidths []int
+		wantText   string
+	}{
+		{
+			name:       "narrower",
+			cols:       10,
+			input:      "abcdefghijklmno",
+			newCols:    5,
+			after:      "p",
+			wantWidths: []int{5, 5, 5, 1},
+			wantText:   "abcdefghijklmnop",
+		},
+		{
+			name:       "wider",
+			cols:       5,
+			input:      "abcdefgh\nxy",
+			newCols:    10,
+			after:      "z",
+			wantWidths: []int{8, 3},
+			wantText:   "abcdefgh\nxyz",
+		},
+		{
+			name:       "cursor in the middle of a wrapped line",
+			cols:       4,
+			input:      "abcdefghij\x1b[A\x1b[2D",
+			newCols:    8,
+			after:      "X",
+			wantWidths: []int{8, 2},
+			wantText:   "abcdXfghij",
+		},
+		{
+			name:       "wide characters aren't split",
+			cols:       10,
+			input:      "ab日本語",
+			newCols:    5,
+			after:      "!",
+			wantWidths: []int{4, 5},
+			wantText:   "ab日本語!",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			s, err := NewScreen(WithSize(test.cols, 5))
+			if err != nil {
+				t.Fatalf("NewScreen() error = %v", err)
+			}
+			s.Write([]byte(test.input))
+			if err := s.SetSize(test.newCols, 5); err != nil {
+				t.Fatalf("s.SetSize(%d, 5) error = %v", test.newCols, err)
+			}
+			s.Write([]byte(test.after))
+
+			if diff := cmp.Diff(lineWidths(s), test.wantWidths); diff != "" {
+				t.Errorf("line widths diff (-got +want):\n%s", diff)
+			}
+			if diff := cmp.Diff(s.AsPlainText(), test.wantText); diff != "" {
+				t.Errorf("AsPlainText() diff (-got +want):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestSetSizeKeepsCursorLine(t *testing.T) {
+	s, err := NewScreen(WithSize(10, 5))
+	if err != nil {
+		t.Fatalf("NewScreen() error = %v", err)
+	}
+	s.Write([]byte("1\n2\n3\n4\n5"))
+	if err := s.SetSize(10, 2); err != nil {
+		t.Fatalf("s.SetSize(10, 2) error = %v", err)
+	}
+	s.Write([]byte("!\x1b[AA"))
+	if diff := cmp.Diff(s.AsPlainText(), "1\n2\n3\n4 A\n5!"); diff != "" {
+		t.Errorf("AsPlainText() diff (-got +want):\n%s", diff)
+	}
+}
+
+func TestSetSizeReflowKeepsLinks(t *testing.T) {
+	s, err := NewScreen(WithSize(6, 5))
+	if err != nil {
+		t.Fatalf("NewScreen() error = %v", err)
+	}
+	s.Write([]byte("see \x1b]8;;http://example.com\x1b\\example\x1b]8;;\x1b\\!"))
+	if err := s.SetSize(20, 5); err != nil {
+		t.Fatalf("s.SetSize(20, 5) error = %v", err)
+	}
+	want := `see <a href="http://example.com">example</a>!`
+	if diff := cmp.Diff(s.AsHTML(), want); diff != "" {
+		t.Errorf("AsHTML() diff (-got +want):\n%s", diff)
+	}
+	if diff := cmp.Diff(lineWidths(s), []int{12}); diff != "" {
+		t.Errorf("line widths diff (-got +want):\n%s", diff)
+	}
+}
+
+func TestResizeSignal(t *testing.T) {
+	tests := []struct {
+		name    string
+		opts    []ScreenOption
+		input   string
+		want    [2]int
+		wantPTY bool
+	}{
+		{
+			name:  "both sizes",
+			input: "\x1b[8;24;80t",
+			want:  [2]int{80, 24},
+		},
+		{
+			name:  "omitted width",
+			input: "\x1b[8;24t",
+			want:  [2]int{20, 24},
+		},
+		{
+			name:  "empty height",
+			input: "\x1b[8;;80t",
+			want:  [2]int{80, 10},
+		},
+		{
+			name:  "zero sizes",
+			input: "\x1b[8;0;0t",
+			want:  [2]int{20, 10},
+		},
+		{
+			name:  "other window operations",
+			input: "\x1b[4;24;80t",
+			want:  [2]int{20, 10},
+		},
+		{
+			name:  "limited to the maximum size",
+			opts:  []ScreenOption{WithMaxSize(100, 50)},
+			input: "\x1b[8;100;500t",
+			want:  [2]int{100, 50},
+		},
+		{
+			name:  "limited to the default limits without a maximum size",
+			input: "\x1b[8;100000;50000000t",
+			want:  [2]int{defaultMaxStreamColumns, defaultMaxStreamLines},
+		},
+		{
+			name:    "declared PTY size limited to the default limits",
+			input:   "\x1b_bk;cols=50000000;lines=100000\x07",
+			want:    [2]int{defaultMaxStreamColumns, defaultMaxStreamLines},
+			wantPTY: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			s, err := NewScreen(append([]ScreenOption{WithSize(20, 10)}, test.opts...)...)
+			if err != nil {
+				t.Fatalf("NewScreen() error = %v", err)
+			}
+			s.Write([]byte(test.input))
+			if diff := cmp.Diff([2]int{s.cols, s.lines}, test.want); diff != "" {
+				t.Errorf("screen size diff (-got +want):\n%s", diff)
+			}
+			if s.ptySizeKnown != test.wantPTY {
+				t.Errorf("s.ptySizeKnown = %t, want %t", s.ptySizeKnown, test.wantPTY)
+			}
+		})
+	}
+}
+
+func TestBlankLineCapacity(t *testing.T) {
+	s, err := NewScreen(WithSize(defaultMaxStreamColumns, 10))
+	if err != nil {
+		t.Fatalf("NewScreen() error = %v", err)
+	}
+	s.Write([]byte("a\nb\nc"))
+	for i, l := range s.screen {
+		if got := cap(l.nodes); got > initialLineCap {
+			t.Errorf("cap(s.screen[%d].nodes) = %d, want <= %d", i, got, initialLineCap)
+		}
+	}
+}
+
+func TestSetSizeReflowElements(t *testing.T) {
+	const link = "\x1b]1339;url=/1;content=a\x07"
+	s, err := NewScreen(WithSize(4, 10), WithLimits(Limits{MaxElementsPerLine: 3}))
+	if err != nil {
+		t.Fatalf("NewScreen() error = %v", err)
+	}
+	s.Write([]byte(link + link + link + "xx"))
+	if err := s.SetSize(3, 10); err != nil {
+		t.Fatalf("s.SetSize(3, 10) error = %v", err)
+	}
+
+	// Each line only has the elements its nodes refer to.
+	var elements []int
+	for _, l := range s.screen {
+		elements = append(elements, len(l.elements))
+	}
+	if diff := cmp.Diff(elements, []int{3, 0}); diff != "" {
+		t.Errorf("elements per line diff (-got +want):\n%s", diff)
+	}
+
+	// So the per-line limit only counts elements on the line being written.
+	s.Write([]byte("\x1b]1339;url=/2;content=b\x07"))
+	want := `<a href="/1">a</a><a href="/1">a</a><a href="/1">a</a>xx<a href="/2">b</a>`
+	if diff := cmp.Diff(s.AsHTML(), want); diff != "" {
+		t.Errorf("AsHTML() diff (-got +want):\n%s", diff)
+	}
+}
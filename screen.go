@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"maps"
 	"math"
+	"slices"
 	"strconv"
 	"strings"
 )
@@ -48,9 +49,51 @@ type Screen struct {
 	// recycled later on.
 	nodeRecycling [][]node
 
+	// Options for rendering HTML, both by AsHTML and for ScrollOutFunc.
+	htmlOptions HTMLOptions
+
+	// While the alternate screen is in use, the main screen is saved here.
+	mainScreen *savedScreen
+
+	// What happens to the contents of the alternate screen when it is exited.
+	altScreenPolicy AltScreenPolicy
+
+	// Whether the window size is the actual size of the PTY the output came
+	// from, which makes absolute cursor positioning reliable.
+	ptySizeKnown bool
+
+	// In origin mode (DECOM), absolute cursor positions are relative to the
+	// scroll region.
+	originMode bool
+
+	// Tab stops by column, or nil for the default (every 8 columns).
+	tabStops []bool
+
+	// The scroll region set by DECSTBM, as lines within the window
+	// (inclusive). scrollBottom is 0 when there is no region.
+	scrollTop, scrollBottom int
+
+	// The format of lines passed to ScrollOutFunc.
+	format Format
+
+	// Limits on the resources input can use, and the number of elements
+	// written so far (for Limits.MaxElements).
+	limits          Limits
+	elementsWritten int
+
+	// Optional store for inline images, which are then referenced by URL.
+	imageSink ImageSink
+
+	// The state of the HTML lines scrolled out so far, such as the number of
+	// lines and whether a section is open.
+	html htmlState
+
 	// Optional callback. If not nil, as each line is scrolled out of the top of
-	// the buffer, this func is called with the HTML.
-	// The line will always have a `\n` suffix.
+	// the buffer, this func is called with the HTML (or the line in the format
+	// set by WithFormat).
+	// The line will always have a `\n` suffix, except that with
+	// HTMLOptions.Sections, lines that open or close a section end with the
+	// <summary> or </details> tag.
 	ScrollOutFunc func(lineHTML string)
 
 	// Processing statistics
@@ -69,6 +112,14 @@ func WithSize(w, h int) ScreenOption {
 	return func(s *Screen) error { return s.SetSize(w, h) }
 }
 
+// WithPTYSize sets the window size to the actual size of the PTY that
+// produced the output. Unlike with WithSize, absolute cursor positions (such
+// as CSI H) are then rendered exactly. The size can also be declared in the
+// output with an APC sequence: ESC _ bk;cols=<cols>;lines=<lines> BEL
+func WithPTYSize(w, h int) ScreenOption {
+	return func(s *Screen) error { return s.setPTYSize(w, h) }
+}
+
 // WithMaxSize sets the screen size limits.
 func WithMaxSize(maxCols, maxLines int) ScreenOption {
 	return func(s *Screen) error {
@@ -84,6 +135,14 @@ func WithMaxSize(maxCols, maxLines int) ScreenOption {
 	}
 }
 
+// WithHTMLOptions sets the options used to render HTML.
+func WithHTMLOptions(opts HTMLOptions) ScreenOption {
+	return func(s *Screen) error {
+		s.htmlOptions = opts
+		return nil
+	}
+}
+
 // NewScreen creates a new screen with various options.
 func NewScreen(opts ...ScreenOption) (*Screen, error) {
 	s := &Screen{
@@ -117,10 +176,47 @@ func (s *Screen) SetSize(cols, lines int) error {
 	if s.maxLines > 0 && lines > s.maxLines {
 		return fmt.Errorf("lines greater than max [%d > %d]", lines, s.maxLines)
 	}
+
+	// Keep the cursor on the same line of the buffer, rather than the same
+	// line of the window.
+	cursor := s.top() + s.y
+	if cols != s.cols && s.mainScreen == nil {
+		// Full-screen programs redraw the alternate screen themselves, so
+		// only the main screen is reflowed.
+		cursor = s.reflow(cols, cursor)
+	}
 	s.cols, s.lines = cols, lines
+	if limit := s.bufferLimit(); limit > 0 {
+		for len(s.screen) > limit {
+			n := len(s.screen)
+			s.scrollOut()
+			cursor -= n - len(s.screen)
+		}
+	}
+	s.y = max(0, min(cursor-s.top(), s.lines))
+	s.x = min(s.x, s.cols)
+
+	// The scroll region no longer fits the window.
+	s.scrollTop, s.scrollBottom = 0, 0
+	return nil
+}
+
+// setPTYSize changes the window size to the actual size of the PTY.
+func (s *Screen) setPTYSize(cols, lines int) error {
+	if err := s.SetSize(cols, lines); err != nil {
+		return err
+	}
+	s.ptySizeKnown = true
 	return nil
 }
 
+// absoluteRows reports if rows in absolute cursor positions refer to known
+// lines: either because the alternate screen is in use, or because the PTY
+// size is known.
+func (s *Screen) absoluteRows() bool {
+	return s.mainScreen != nil || s.ptySizeKnown
+}
+
 // ansiInt parses s as a decimal integer. If s is empty or malformed, it
 // returns 1.
 func ansiInt(s string) int {
@@ -219,29 +315,14 @@ func (s *Screen) currentLineForWriting() *screenLine {
 		// This, and the final line, are the only instances in which newline should
 		// be false.
 		s.currentLine().newline = false
-		s.y++
+		s.lineFeed()
 	}
 	// Ensure there are enough lines on screen to start writing here.
 	for s.currentLine() == nil {
-		// If maxLines is not in use, or adding a new line would not make it
-		// larger than maxLines, then just allocate a new line.
-		if s.maxLines <= 0 || len(s.screen)+1 <= s.maxLines {
-			var nodes []node
-			if len(s.nodeRecycling) > 0 {
-				// Pop one off the end of nodeRecycling
-				r1 := len(s.nodeRecycling) - 1
-				nodes = s.nodeRecycling[r1]
-				s.nodeRecycling = s.nodeRecycling[:r1]
-			}
-			if nodes == nil {
-				// No slices available for recycling, make a new one.
-				nodes = make([]node, 0, s.cols)
-			}
-			newLine := screenLine{
-				nodes:   nodes,
-				newline: true,
-			}
-			s.screen = append(s.screen, newLine)
+		// If there's no limit on the buffer size, or adding a new line would not
+		// make it larger than the limit, then just allocate a new line.
+		if limit := s.bufferLimit(); limit <= 0 || len(s.screen)+1 <= limit {
+			s.screen = append(s.screen, s.blankLine())
 			if s.y >= s.lines {
 				// Because the "window" is always the last s.lines of s.screen
 				// (or all of them, if there are fewer lines than s.lines)
@@ -252,54 +333,10 @@ func (s *Screen) currentLineForWriting() *screenLine {
 			continue
 		}
 
-		// maxLines is in effect, and adding a new line would make the screen
-		// larger than maxLines.
-		// Pass the whole line being scrolled out to ScrollOutFunc if available,
-		// otherwise just scroll out 1 line to nowhere.
-		scrollOutTo := 1
-		if s.ScrollOutFunc != nil {
-			// Whole lines need to be passed to the callback. Find the end of
-			// the line (the screen line with newline = true).
-			// The majority of the time this will just be the first screen line.
-			// If it's all one enormous line, stop at the top of the screen.
-			// (so, allow scrollout to eat all of the "scrollback" but none of
-			// the "visible screen". We're talking a line that's 160*200
-			// chars long for the top of the screen to be reached that way.)
-			scrollOutTo = s.top()
-			if s.top() == 0 {
-				// We still need to scroll out a line, even if there are no lines above
-				// the top of the window. Get the next line.
-				scrollOutTo = len(s.screen)
-			}
-			for i, l := range s.screen[:scrollOutTo] {
-				if l.newline {
-					scrollOutTo = i + 1
-					break
-				}
-			}
-			s.ScrollOutFunc(lineToHTML(s.screen[:scrollOutTo]))
-		}
-		for i := range scrollOutTo {
-			s.nodeRecycling = append(s.nodeRecycling, s.screen[i].nodes[:0])
-		}
-		s.LinesScrolledOut += scrollOutTo
-
-		var nodes []node
-		if r1 := len(s.nodeRecycling) - 1; r1 >= 0 {
-			// Make a new line on the bottom using a recycled node slice. There's
-			// usually at least one we just added.
-			nodes = s.nodeRecycling[r1]
-			s.nodeRecycling = s.nodeRecycling[:r1]
-		} else {
-			// No nodes to recycle, make a new node slice. This happens when we scroll
-			// out a line that consisted of no screenlines.
-			nodes = make([]node, 0, s.cols)
-		}
-		newLine := screenLine{
-			nodes:   nodes,
-			newline: true,
-		}
-		s.screen = append(s.screen[scrollOutTo:], newLine)
+		// The limit is in effect, and adding a new line would make the screen
+		// larger than the limit.
+		s.scrollOut()
+		s.screen = append(s.screen, s.blankLine())
 
 		// Since the buffer added 1 line, s.y moves upwards.
 		s.y--
@@ -308,20 +345,177 @@ func (s *Screen) currentLineForWriting() *screenLine {
 	return s.currentLine()
 }
 
+// bufferLimit returns the maximum number of lines to keep in the buffer, or 0
+// or less for no limit.
+func (s *Screen) bufferLimit() int {
+	if s.mainScreen != nil {
+		// The alternate screen has no scrollback.
+		return s.lines
+	}
+	return s.maxLines
+}
+
+// initialLineCap is the most node storage allocated up front for a new line.
+// Wider lines grow as they are written to.
+const initialLineCap = 160
+
+// blankLine returns a new empty line, recycling node storage if available.
+func (s *Screen) blankLine() screenLine {
+	var nodes []node
+	if r1 := len(s.nodeRecycling) - 1; r1 >= 0 {
+		// Pop one off the end of nodeRecycling
+		nodes = s.nodeRecycling[r1]
+		s.nodeRecycling = s.nodeRecycling[:r1]
+	}
+	if nodes == nil {
+		// No slices available for recycling, make a new one.
+		nodes = make([]node, 0, min(s.cols, initialLineCap))
+	}
+	return screenLine{
+		nodes:   nodes,
+		newline: true,
+	}
+}
+
+// scrollOut removes at least one line from the top of the buffer.
+// Pass the whole line being scrolled out to ScrollOutFunc if available,
+// otherwise just scroll out 1 line to nowhere.
+func (s *Screen) scrollOut() {
+	// Lines scrolling off the top of the alternate screen are gone for good.
+	alt := s.mainScreen != nil
+
+	scrollOutTo := 1
+	if s.ScrollOutFunc != nil && !alt {
+		// Whole lines need to be passed to the callback. Find the end of
+		// the line (the screen line with newline = true).
+		// The majority of the time this will just be the first screen line.
+		// If it's all one enormous line, stop at the top of the screen.
+		// (so, allow scrollout to eat all of the "scrollback" but none of
+		// the "visible screen". We're talking a line that's 160*200
+		// chars long for the top of the screen to be reached that way.)
+		scrollOutTo = s.top()
+		if s.top() == 0 {
+			// We still need to scroll out a line, even if there are no lines above
+			// the top of the window. Get the next line.
+			scrollOutTo = len(s.screen)
+		}
+		for i, l := range s.screen[:scrollOutTo] {
+			if l.newline {
+				scrollOutTo = i + 1
+				break
+			}
+		}
+		line := s.screen[:scrollOutTo]
+		lines := s.html.lines
+		s.ScrollOutFunc(s.lineToFormat(line))
+		// Count the line here, whatever the format, so that line numbers in
+		// HTML stay right.
+		s.html.lines = lines
+		if s.html.counts(line, s.htmlOptions) {
+			s.html.lines++
+		}
+	} else if !alt && s.screen[0].newline && s.html.counts(s.screen[:1], s.htmlOptions) {
+		// The line is gone, but still counts.
+		s.html.lines++
+	}
+	for i := range scrollOutTo {
+		s.nodeRecycling = append(s.nodeRecycling, s.screen[i].nodes[:0])
+	}
+	if !alt {
+		s.LinesScrolledOut += scrollOutTo
+	}
+	s.screen = s.screen[scrollOutTo:]
+}
+
+// moveTo moves the cursor to a row and column within the window (counting
+// from 0). In origin mode, the row is relative to the scroll region, and the
+// cursor can't leave the region.
+func (s *Screen) moveTo(row, col int) {
+	top, bottom := 0, s.lines-1
+	if s.originMode {
+		top, bottom = s.scrollMargins()
+	}
+	s.y = max(top, min(top+row, bottom))
+	s.x = max(0, min(col, s.cols-1))
+}
+
 // Write a character to the screen's current X&Y, along with the current screen style
 func (s *Screen) write(data rune) {
+	width := runeWidth(data)
+	if width == 0 || s.joiningPrevious() {
+		// Combining marks (and characters following a zero width joiner)
+		// belong in the same cell as the previous character.
+		if s.combine(data) {
+			return
+		}
+		// There's no previous character, so give it a cell of its own.
+		width = 1
+	}
+	if width == 2 && s.cols < 2 {
+		width = 1
+	}
+	if width == 2 && s.x == s.cols-1 {
+		// A wide character doesn't fit in the last column, so it wraps onto
+		// the next line (see currentLineForWriting).
+		s.x = s.cols
+	}
+
 	line := s.currentLineForWriting()
-	line.writeNode(s.x, node{blob: data, style: s.style})
+	for i := range width {
+		n := node{blob: data, style: s.style}
+		if i > 0 {
+			// The second column of a wide character holds no character of its
+			// own, but has the same style (and link) as the first.
+			n = node{blob: ' ', style: s.style | sbWideTail}
+		}
+		line.writeNode(s.x, n)
 
-	// OSC 8 links work like a style.
-	if s.style.hyperlink() {
-		if line.hyperlinks == nil {
-			line.hyperlinks = make(map[int]string)
+		// OSC 8 links work like a style.
+		if s.style.hyperlink() {
+			if line.hyperlinks == nil {
+				line.hyperlinks = make(map[int]string)
+			}
+			line.hyperlinks[s.x] = s.urlBrush
 		}
-		line.hyperlinks[s.x] = s.urlBrush
+
+		s.x++
 	}
+}
 
-	s.x++
+// previousCell returns the line the cursor is on and the position of the
+// character before the cursor, or nil if there is no such character.
+func (s *Screen) previousCell() (*screenLine, int) {
+	line := s.currentLine()
+	x := s.x - 1
+	if line == nil || x < 0 || x >= len(line.nodes) {
+		return nil, 0
+	}
+	if line.nodes[x].style.wideTail() && x > 0 {
+		x--
+	}
+	return line, x
+}
+
+// joiningPrevious reports if the character before the cursor ends with a
+// zero width joiner, which joins the next character to it (as in emoji such
+// as 👩‍💻).
+func (s *Screen) joiningPrevious() bool {
+	line, x := s.previousCell()
+	return line != nil && strings.HasSuffix(line.combining[x], "\u200d")
+}
+
+// combine adds a zero-width character to the character before the cursor.
+// It reports false if there is no character there to add it to.
+func (s *Screen) combine(data rune) bool {
+	line, x := s.previousCell()
+	if line == nil || line.nodes[x].style.element() {
+		return false
+	}
+	if line.combining == nil {
+		line.combining = make(map[int]string)
+	}
+	line.combining[x] += string(data)
+	return true
 }
 
 // Append a character to the screen
@@ -388,13 +582,8 @@ func (s *Screen) applyEscape(code rune, instructions []string) {
 	}
 
 	if strings.HasPrefix(inst(0), "?") {
-		// These are typically "private" control sequences, e.g.
-		// - show/hide cursor (not relevant)
-		// - enable/disable focus reporting (not relevant)
-		// - alternate screen buffer (not implemented)
-		// - bracketed paste mode (not relevant)
-		// Particularly, "show cursor" is CSI ?25h, which would be picked up
-		// below if we didn't handle it.
+		// These are "private" control sequences. The ones we care about
+		// (DECSET/DECRST) are handled by setMode.
 		return
 	}
 
@@ -425,11 +614,19 @@ func (s *Screen) applyEscape(code rune, instructions []string) {
 		s.x = min(s.x, s.cols-1)
 
 	case 'H': // Cursor Position Absolute: Go to row n and column m (default 1;1).
+		if s.absoluteRows() {
+			// The alternate screen has no scrollback and starts out blank, so
+			// there is no previous content to preserve (see below), and rows
+			// count from the top of the window as expected. Similarly, if the
+			// PTY size is known, rows count from the top of the window.
+			s.moveTo(ansiInt(inst(0))-1, ansiInt(inst(1))-1)
+			break
+		}
 		//
 		// There are a variety of agent versions still in use, which have
 		// different PTY window settings. Although we emulate a window size
-		// here, we can't know for sure which line CSI H is referring to until
-		// we have a mechanism to report the real window size that was used.
+		// here, we can't know for sure which line CSI H is referring to unless
+		// the real window size that was used is reported (see WithPTYSize).
 		// If the program output CSI 1H, we don't know if that's the top of a
 		// 80x25 window or a 160x100 window, which could be either 24 lines or
 		// 99 lines above the current position.
@@ -517,8 +714,50 @@ func (s *Screen) applyEscape(code rune, instructions []string) {
 			s.currentLine().clearAll()
 		}
 
-	case 'M':
+	case 'd': // Vertical Position Absolute: Go to row n (default 1)
+		// Like CSI H, this can only be done reliably if rows are known.
+		if s.absoluteRows() {
+			s.moveTo(ansiInt(inst(0))-1, s.x)
+		}
+
+	case 'I': // Cursor Horizontal Tab: go forward n tab stops
+		s.tabForward(max(1, ansiInt(inst(0))))
+
+	case 'Z': // Cursor Backward Tab: go back n tab stops
+		s.tabBackward(max(1, ansiInt(inst(0))))
+
+	case 'g': // Tab Clear
+		s.clearTabStops(inst(0))
+
+	case 'L': // Insert Line: insert n blank lines at the cursor
+		if top, bottom := s.scrollMargins(); s.y >= top && s.y <= bottom {
+			s.insertLines(s.y, bottom, max(1, ansiInt(inst(0))))
+			s.x = 0
+		}
+
+	case 'M': // Delete Line: delete n lines at the cursor
+		if top, bottom := s.scrollMargins(); s.y >= top && s.y <= bottom {
+			s.deleteLines(s.y, bottom, max(1, ansiInt(inst(0))))
+			s.x = 0
+		}
+
+	case '@': // Insert Character: insert n blank characters at the cursor
+		s.currentLine().insertChars(s.x, max(1, ansiInt(inst(0))), s.cols)
+
+	case 'P': // Delete Character: delete n characters at the cursor
+		s.currentLine().deleteChars(s.x, max(1, ansiInt(inst(0))))
+
+	case 'X': // Erase Character: erase n characters from the cursor
+		s.currentLine().clear(s.x, s.x+max(1, ansiInt(inst(0)))-1)
+
+	case 'm':
 		s.color(instructions)
+
+	case 'S': // Scroll Up: scroll the scroll region up n lines
+		s.scrollUp(max(1, ansiInt(inst(0))))
+
+	case 'T': // Scroll Down: scroll the scroll region down n lines
+		s.scrollDown(max(1, ansiInt(inst(0))))
 	}
 }
 
@@ -528,32 +767,31 @@ func (s *Screen) Write(input []byte) (int, error) {
 	return len(input), nil
 }
 
-// AsHTML returns the contents of the current screen buffer as HTML.
+// AsHTML returns the contents of the current screen buffer as HTML, using the
+// screen's HTML options.
 func (s *Screen) AsHTML() string {
-	var sb strings.Builder
+	return s.AsHTMLWithOptions(s.htmlOptions)
+}
 
-	screen := s.screen
-	for len(screen) > 0 {
-		// Find lineEnd of a line, or failing that, go to the end of the screen.
-		lineEnd := len(screen)
-		for i, l := range screen {
-			if l.newline {
-				lineEnd = i + 1
-				break
-			}
-		}
-		sb.WriteString(lineToHTML(screen[:lineEnd]))
-		screen = screen[lineEnd:]
+// AsHTMLWithOptions returns the contents of the current screen buffer as HTML,
+// using the given options.
+// Any section left open by lines passed to ScrollOutFunc is continued, and
+// closed at the end.
+func (s *Screen) AsHTMLWithOptions(opts HTMLOptions) string {
+	// Copy the state, so that the contents can be rendered more than once.
+	st := s.html
+	var sb strings.Builder
+	for _, parts := range logicalLines(s.contents()) {
+		sb.WriteString(st.line(parts, opts))
 	}
-
 	// For backwards compatibility the final newline is trimmed.
-	return strings.TrimSuffix(sb.String(), "\n")
+	return strings.TrimSuffix(sb.String(), "\n") + st.end()
 }
 
 // AsPlainText renders the screen without any ANSI style etc.
 func (s *Screen) AsPlainText() string {
 	var sb strings.Builder
-	for _, line := range s.screen {
+	for _, line := range s.contents() {
 		sb.WriteString(line.asPlain())
 	}
 
@@ -572,10 +810,15 @@ func (s *Screen) newLine() {
 	if line := s.currentLine(); line != nil {
 		line.newline = true
 	}
-	s.y++
+	s.lineFeed()
 }
 
 func (s *Screen) revNewLine() {
+	if s.scrollBottom != 0 && s.y == s.scrollTop {
+		// At the top of the scroll region, the region scrolls down instead.
+		s.scrollDown(1)
+		return
+	}
 	if s.y > 0 {
 		s.y--
 	}
@@ -612,6 +855,11 @@ type screenLine struct {
 	// So a map is used for sparse storage, only lazily created when text with
 	// a link style is written.
 	hyperlinks map[int]string
+
+	// combining stores zero-width characters (combining marks, zero width
+	// joiners, and so on) by the X position of the character they belong to.
+	// Like hyperlinks, these are rare enough to store sparsely.
+	combining map[int]string
 }
 
 func (l *screenLine) clearAll() {
@@ -620,6 +868,7 @@ func (l *screenLine) clearAll() {
 	}
 	l.nodes = l.nodes[:0]
 	l.newline = true
+	l.combining = nil
 }
 
 // clear clears part (or all) of a line. The range to clear is inclusive
@@ -642,21 +891,104 @@ func (l *screenLine) clear(xStart, xEnd int) {
 		return
 	}
 
+	for i := range l.combining {
+		if i >= xStart && i <= xEnd {
+			delete(l.combining, i)
+		}
+	}
+
+	// Clearing half of a wide character clears the other half.
+	if l.nodes[xStart].style.wideTail() && xStart > 0 {
+		xStart--
+		delete(l.combining, xStart)
+	}
+
 	if xEnd >= len(l.nodes)-1 {
 		// Clear from start to end of the line
 		l.nodes = l.nodes[:xStart]
 		return
 	}
 
+	if l.nodes[xEnd+1].style.wideTail() {
+		xEnd++
+	}
+
 	for i := xStart; i <= xEnd; i++ {
 		l.nodes[i] = emptyNode
 	}
 }
 
+// insertChars inserts n blank characters at x, moving the rest of the line to
+// the right. Characters moved past the width of the window (cols) are lost.
+func (l *screenLine) insertChars(x, n, cols int) {
+	if l == nil || x >= len(l.nodes) {
+		// Inserting after the end of the line changes nothing.
+		return
+	}
+	n = min(n, cols-x)
+	l.nodes = slices.Insert(l.nodes, x, slices.Repeat([]node{emptyNode}, n)...)
+	if len(l.nodes) > cols {
+		l.nodes = l.nodes[:cols]
+	}
+	l.shiftColumns(x, n)
+}
+
+// deleteChars deletes n characters at x, moving the rest of the line to the
+// left.
+func (l *screenLine) deleteChars(x, n int) {
+	if l == nil || x >= len(l.nodes) {
+		return
+	}
+	end := min(x+n, len(l.nodes))
+	l.nodes = slices.Delete(l.nodes, x, end)
+	for i := x; i < end; i++ {
+		delete(l.hyperlinks, i)
+		delete(l.combining, i)
+	}
+	l.shiftColumns(end, x-end)
+}
+
+// shiftColumns moves the hyperlinks and combining characters at or after x
+// by n columns (which may be negative) to keep up with the nodes they belong
+// to.
+func (l *screenLine) shiftColumns(x, n int) {
+	l.hyperlinks = shiftKeys(l.hyperlinks, x, n, len(l.nodes))
+	l.combining = shiftKeys(l.combining, x, n, len(l.nodes))
+}
+
+// shiftKeys returns a copy of m with the keys at or after x moved by n,
+// dropping any that end up at or after limit.
+func shiftKeys[V any](m map[int]V, x, n, limit int) map[int]V {
+	if len(m) == 0 || n == 0 {
+		return m
+	}
+	shifted := make(map[int]V, len(m))
+	for i, v := range m {
+		if i >= x {
+			i += n
+		}
+		if i < limit {
+			shifted[i] = v
+		}
+	}
+	return shifted
+}
+
 func (l *screenLine) writeNode(x int, n node) {
 	// Add columns if currently shorter than the cursor's x position
 	for i := len(l.nodes); i <= x; i++ {
 		l.nodes = append(l.nodes, emptyNode)
 	}
+
+	// Overwriting half of a wide character blanks the other half.
+	if l.nodes[x].style.wideTail() && x > 0 {
+		l.nodes[x-1] = emptyNode
+		delete(l.combining, x-1)
+	}
+	if x+1 < len(l.nodes) && l.nodes[x+1].style.wideTail() {
+		l.nodes[x+1] = emptyNode
+	}
+
 	l.nodes[x] = n
+	delete(l.combining, x)
 }
@@ -60,3 +60,32 @@ func TestCurrentLineForWriting(t *testing.T) {
 		})
 	}
 }
+
+func TestScrollOutFuncUsesHTMLOptions(t *testing.T) {
+	s, err := NewScreen(
+		WithMaxSize(0, 1),
+		WithHTMLOptions(HTMLOptions{InlineStyles: true}),
+	)
+	if err != nil {
+		t.Fatalf("NewScreen() error = %v", err)
+	}
+	var got []string
+	s.ScrollOutFunc = func(line string) { got = append(got, line) }
+	s.Write([]byte("\x1b[31mred\nafter"))
+
+	want := []string{"<span style=\"color:#ff7070\">red</span>\n"}
+	if diff := cmp.Diff(got, want); diff != "" {
+		t.Errorf("scrolled out lines diff (-got +want):\n%s", diff)
+	}
+}
+
+func TestWithPTYSize(t *testing.T) {
+	s, err := NewScreen(WithPTYSize(20, 4))
+	if err != nil {
+		t.Fatalf("NewScreen() error = %v", err)
+	}
+	s.Write([]byte("one\ntwo\nthree\x1b[2;1Htoo\x1b[1;4H!"))
+	if diff := cmp.Diff(s.AsPlainText(), "one!\ntoo\nthree"); diff != "" {
+		t.Errorf("AsPlainText() diff (-got +want):\n%s", diff)
+	}
+}
@@ -0,0 +1,164 @@
+package terminal
+
+import "slices"
+
+// setScrollRegion handles DECSTBM (CSI top;bottom r), which limits scrolling
+// to the lines between the top and bottom margins. Lines outside the region
+// stay put, which progress UIs use to keep status lines at the bottom of the
+// window while other output scrolls above them.
+func (s *Screen) setScrollRegion(instructions []string) {
+	inst := func(i int) string {
+		if i < 0 || i >= len(instructions) {
+			return ""
+		}
+		return instructions[i]
+	}
+
+	top := max(0, ansiInt(inst(0))-1)
+	bottom := s.lines - 1
+	if b := ansiInt(inst(1)); inst(1) != "" && b > 0 {
+		bottom = min(b-1, bottom)
+	}
+	if top >= bottom {
+		// The region must be at least two lines. Real terminals ignore the
+		// sequence otherwise.
+		return
+	}
+	if top == 0 && bottom == s.lines-1 {
+		// The whole window, which is the same as having no region.
+		s.scrollTop, s.scrollBottom = 0, 0
+	} else {
+		s.scrollTop, s.scrollBottom = top, bottom
+	}
+
+	// DECSTBM also moves the cursor to the top left. For the same reasons as
+	// CSI H (see applyEscape), that can only be done reliably if rows are
+	// known.
+	s.x = 0
+	if s.absoluteRows() {
+		s.moveTo(0, 0)
+	}
+}
+
+// scrollMargins returns the top and bottom lines of the scroll region within
+// the window (inclusive). Without a region, this is the whole window.
+func (s *Screen) scrollMargins() (top, bottom int) {
+	if s.scrollBottom == 0 {
+		return 0, s.lines - 1
+	}
+	return s.scrollTop, s.scrollBottom
+}
+
+// lineFeed moves the cursor down a line, scrolling the scroll region if the
+// cursor is on its bottom line.
+func (s *Screen) lineFeed() {
+	switch {
+	case s.scrollBottom == 0:
+		// No region. The window scrolls as lines are added to the buffer
+		// (see currentLineForWriting).
+		s.y++
+
+	case s.y == s.scrollBottom:
+		s.scrollUp(1)
+
+	case s.y < s.lines-1:
+		// Below the region, the cursor stops at the bottom of the window.
+		s.y++
+	}
+}
+
+// scrollUp scrolls the contents of the scroll region up by n lines, adding
+// blank lines at the bottom of the region.
+func (s *Screen) scrollUp(n int) {
+	top, bottom := s.scrollMargins()
+	if top != 0 || s.mainScreen != nil {
+		s.deleteLines(top, bottom, n)
+		return
+	}
+	for range min(n, s.lines) {
+		// Lines below the end of the buffer are blank, so there's no need to
+		// scroll them (or to add them to the buffer), unless the cursor is
+		// there.
+		s.scrollIntoScrollback(min(bottom, max(len(s.screen)-1-s.top(), s.y)))
+	}
+}
+
+// scrollIntoScrollback scrolls a region at the top of the main screen up by
+// one line. The top line moves into the scrollback (above the window), as it
+// would in a real terminal, so that it can be scrolled out of the buffer
+// like any other line.
+func (s *Screen) scrollIntoScrollback(bottom int) {
+	wtop := s.top()
+	end := wtop + bottom + 1
+	for len(s.screen) < end {
+		s.screen = append(s.screen, s.blankLine())
+	}
+	s.screen = slices.Insert(s.screen, end, s.blankLine())
+
+	if s.top() == wtop {
+		// The window isn't full yet, so instead of the window moving down
+		// over the buffer, everything from the bottom of the region down
+		// moved down the window. Move the cursor and the region with it.
+		s.y++
+		if s.scrollBottom != 0 {
+			s.scrollBottom++
+			if s.scrollTop == 0 && s.scrollBottom >= s.lines-1 {
+				s.scrollBottom = 0
+			}
+		}
+	}
+
+	// Trim the buffer back down to size, if needed. This only removes lines
+	// above the window.
+	if limit := s.bufferLimit(); limit > 0 {
+		for len(s.screen) > limit {
+			s.scrollOut()
+		}
+	}
+}
+
+// scrollDown scrolls the contents of the scroll region down by n lines,
+// adding blank lines at the top of the region.
+func (s *Screen) scrollDown(n int) {
+	top, bottom := s.scrollMargins()
+	s.insertLines(top, bottom, n)
+}
+
+// deleteLines deletes n lines starting at line top of the window, moving the
+// lines below up and adding blank lines at line bottom. Lines below bottom
+// stay where they are.
+func (s *Screen) deleteLines(top, bottom, n int) {
+	for range min(n, bottom-top+1) {
+		wtop := s.top()
+		start := wtop + top
+		if start >= len(s.screen) {
+			// The rest of the region is blank.
+			return
+		}
+		s.nodeRecycling = append(s.nodeRecycling, s.screen[start].nodes[:0])
+		s.screen = slices.Delete(s.screen, start, start+1)
+
+		if end := wtop + bottom; end <= len(s.screen) {
+			s.screen = slices.Insert(s.screen, end, s.blankLine())
+		}
+	}
+}
+
+// insertLines inserts n blank lines at line top of the window, moving the
+// lines below down. Lines pushed past line bottom are lost, and lines below
+// bottom stay where they are.
+func (s *Screen) insertLines(top, bottom, n int) {
+	for range min(n, bottom-top+1) {
+		wtop := s.top()
+		start := wtop + top
+		if start >= len(s.screen) {
+			// The rest of the region is blank.
+			return
+		}
+		if end := wtop + bottom; end < len(s.screen) {
+			s.nodeRecycling = append(s.nodeRecycling, s.screen[end].nodes[:0])
+			s.screen = slices.Delete(s.screen, end, end+1)
+		}
+		s.screen = slices.Insert(s.screen, start, s.blankLine())
+	}
+}
@@ -0,0 +1,108 @@
+package terminal
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestScrollRegion(t *testing.T) {
+	tests := []struct {
+		name  string
+		lines int
+		input string
+		want  string
+	}{
+		{
+			name:  "status line below the region stays put",
+			lines: 4,
+			input: "a\nb\nc\nstatus\x1b[1;3r\x1b[1A\nd\ne",
+			want:  "a\nb\nc\nd\ne\nstatus",
+		},
+		{
+			name:  "region in a window that isn't full yet",
+			lines: 10,
+			input: "a\nb\nstatus\x1b[1;2r\x1b[1A\nc\nd",
+			want:  "a\nb\nc\nd\nstatus",
+		},
+		{
+			name:  "header above the region is kept",
+			lines: 4,
+			input: "header\n1\n2\n3\x1b[2;4r\x1b[3B\n4\n5",
+			want:  "header\n3\n4\n5",
+		},
+		{
+			name:  "CSI S scrolls the region up",
+			lines: 4,
+			input: "header\n1\n2\n3\x1b[2;4r\x1b[2S",
+			want:  "header\n3\n&nbsp;\n&nbsp;",
+		},
+		{
+			name:  "CSI T scrolls the window down",
+			lines: 3,
+			input: "1\n2\n3\x1b[T",
+			want:  "&nbsp;\n1\n2",
+		},
+		{
+			name:  "reverse index at the top of the region",
+			lines: 4,
+			input: "a\nb\nc\nd\x1b[2;3r\x1b[2A\x1bMx",
+			want:  "a\nx\nb\nd",
+		},
+		{
+			name:  "reverse index without a region",
+			lines: 4,
+			input: "a\nb\x1bM\x1bM\rx",
+			want:  "x\nb",
+		},
+		{
+			name:  "invalid region is ignored",
+			lines: 3,
+			input: "a\x1b[3;2r\nb\nc\nd",
+			want:  "a\nb\nc\nd",
+		},
+		{
+			name:  "resetting the region",
+			lines: 3,
+			input: "a\nb\nstatus\x1b[1;2r\x1b[1A\nc\x1b[r\n\nd",
+			want:  "a\nb\nc\nstatus\nd",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			s, err := NewScreen(WithSize(80, test.lines))
+			if err != nil {
+				t.Fatalf("NewScreen() error = %v", err)
+			}
+			s.Write([]byte(test.input))
+			if diff := cmp.Diff(s.AsHTML(), test.want); diff != "" {
+				t.Errorf("AsHTML() diff (-got +want):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestScrollRegionScrollOut(t *testing.T) {
+	s, err := NewScreen(WithMaxSize(0, 4))
+	if err != nil {
+		t.Fatalf("NewScreen() error = %v", err)
+	}
+	var got []string
+	s.ScrollOutFunc = func(line string) { got = append(got, line) }
+
+	s.Write([]byte("a\nb\nc\nstatus\x1b[1;3r\x1b[1A\nd\ne\nf"))
+
+	// Only lines leaving the top of the region (and so the whole buffer) are
+	// scrolled out. The status line stays at the bottom.
+	want := []string{"a\n", "b\n", "c\n"}
+	if diff := cmp.Diff(got, want); diff != "" {
+		t.Errorf("scrolled out lines diff (-got +want):\n%s", diff)
+	}
+	if got, want := s.LinesScrolledOut, 3; got != want {
+		t.Errorf("s.LinesScrolledOut = %d, want %d", got, want)
+	}
+	if diff := cmp.Diff(s.AsHTML(), "d\ne\nf\nstatus"); diff != "" {
+		t.Errorf("AsHTML() diff (-got +want):\n%s", diff)
+	}
+}
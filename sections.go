@@ -0,0 +1,79 @@
+package terminal
+
+import "strings"
+
+// Section headers. Buildkite log groups are lines starting with one of the
+// three markers; GitHub Actions groups are opened with ::group:: and closed
+// with ::endgroup::.
+const (
+	sectionCollapsedMarker = "--- "
+	sectionExpandedMarker  = "+++ "
+	sectionMutedMarker     = "~~~ "
+	sectionGroupMarker     = "::group::"
+	sectionEndGroupMarker  = "::endgroup::"
+)
+
+// sectionLine renders the parts of a line as HTML, where section headers
+// open a <details> block (closing the previous one), with the header as its
+// <summary>. Lines that open or close a section don't end with \n, since a
+// newline just inside or after a <details> block would show up as an empty
+// line.
+func (st *htmlState) sectionLine(parts []screenLine, opts HTMLOptions) string {
+	first := &parts[0]
+	var open string
+	var markerLen int
+	switch {
+	case hasMarker(first, sectionCollapsedMarker):
+		open, markerLen = `<details class="term-section">`, len(sectionCollapsedMarker)
+	case hasMarker(first, sectionExpandedMarker):
+		open, markerLen = `<details class="term-section" open>`, len(sectionExpandedMarker)
+	case hasMarker(first, sectionMutedMarker):
+		open, markerLen = `<details class="term-section term-section-muted">`, len(sectionMutedMarker)
+	case hasMarker(first, sectionGroupMarker):
+		open, markerLen = `<details class="term-section">`, len(sectionGroupMarker)
+	case hasMarker(first, sectionEndGroupMarker):
+		// The marker itself isn't shown.
+		return st.end()
+	default:
+		return st.numberedLine(parts, opts)
+	}
+
+	title := strings.TrimSuffix(st.numberedLine(trimNodes(parts, markerLen), opts), "\n")
+	out := st.end() + open + "<summary>" + title + "</summary>"
+	st.sectionOpen = true
+	return out
+}
+
+// end closes the open section, if there is one.
+func (st *htmlState) end() string {
+	if !st.sectionOpen {
+		return ""
+	}
+	st.sectionOpen = false
+	return "</details>"
+}
+
+// hasMarker reports if the line starts with the marker, written as text.
+func hasMarker(l *screenLine, marker string) bool {
+	if len(l.nodes) < len(marker) {
+		return false
+	}
+	for i, r := range marker {
+		n := l.nodes[i]
+		if n.style.element() || n.style.conceal() || n.blob != r || l.combining[i] != "" {
+			return false
+		}
+	}
+	return true
+}
+
+// trimNodes returns the parts of a line with the first n nodes of the first
+// part removed.
+func trimNodes(parts []screenLine, n int) []screenLine {
+	first := parts[0]
+	end := len(first.nodes)
+	first.nodes = first.nodes[n:]
+	first.hyperlinks = subKeys(first.hyperlinks, n, end)
+	first.combining = subKeys(first.combining, n, end)
+	return append([]screenLine{first}, parts[1:]...)
+}
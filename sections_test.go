@@ -0,0 +1,144 @@
+package terminal
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestScreenAsHTMLWithSections(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{
+			name:  "buildkite groups",
+			input: "before\n--- collapsed\none\n+++ expanded\ntwo\n~~~ muted\nthree",
+			want: "before\n" +
+				`<details class="term-section"><summary>collapsed</summary>one` + "\n" +
+				`</details><details class="term-section" open><summary>expanded</summary>two` + "\n" +
+				`</details><details class="term-section term-section-muted"><summary>muted</summary>three</details>`,
+		},
+		{
+			name:  "github actions groups",
+			input: "::group::Install\nnpm ci\n::endgroup::\nafter",
+			want:  `<details class="term-section"><summary>Install</summary>npm ci` + "\n" + `</details>after`,
+		},
+		{
+			name:  "endgroup without a group",
+			input: "a\n::endgroup::\nb",
+			want:  "a\nb",
+		},
+		{
+			name:  "headers keep their styles and timestamps",
+			input: "\x1b_bk;t=1700000000000\x07--- \x1b[1mBuild\x1b[0m\nok",
+			want:  `<details class="term-section"><summary><time datetime="2023-11-14T22:13:20Z">2023-11-14T22:13:20Z</time><span class="term-fg1">Build</span></summary>ok</details>`,
+		},
+		{
+			name:  "marker must be at the start of the line",
+			input: " --- not a header\n---no space",
+			want:  " --- not a header\n---no space",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			s, err := NewScreen(WithHTMLOptions(HTMLOptions{Sections: true}))
+			if err != nil {
+				t.Fatalf("NewScreen() = %v", err)
+			}
+			s.Write([]byte(test.input))
+			if diff := cmp.Diff(s.AsHTML(), test.want); diff != "" {
+				t.Errorf("s.AsHTML() diff (-got +want):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestScreenAsHTMLWithoutSections(t *testing.T) {
+	s, err := NewScreen()
+	if err != nil {
+		t.Fatalf("NewScreen() = %v", err)
+	}
+	s.Write([]byte("--- header\n::group::x"))
+	want := "--- header\n::group::x"
+	if diff := cmp.Diff(s.AsHTML(), want); diff != "" {
+		t.Errorf("s.AsHTML() diff (-got +want):\n%s", diff)
+	}
+}
+
+func TestSectionsScrollOut(t *testing.T) {
+	const input = "--- one\na\nb\n+++ two\nc\n::group::three\nd\n::endgroup::\ne\n--- four\nf"
+
+	s, err := NewScreen(WithHTMLOptions(HTMLOptions{Sections: true}))
+	if err != nil {
+		t.Fatalf("NewScreen() = %v", err)
+	}
+	s.Write([]byte(input))
+	want := s.AsHTML()
+
+	// Every line scrolls out except the last two, so the final section is
+	// opened in the scrolled out lines and closed by AsHTML.
+	s, err = NewScreen(WithMaxSize(0, 2), WithHTMLOptions(HTMLOptions{Sections: true}))
+	if err != nil {
+		t.Fatalf("NewScreen() = %v", err)
+	}
+	var scrolledOut strings.Builder
+	s.ScrollOutFunc = func(line string) { scrolledOut.WriteString(line) }
+	s.Write([]byte(input))
+
+	got := scrolledOut.String() + s.AsHTML()
+	if diff := cmp.Diff(got, want); diff != "" {
+		t.Errorf("scrolled out + s.AsHTML() diff (-got +want):\n%s", diff)
+	}
+	if got, want := strings.Count(got, "<details"), strings.Count(got, "</details>"); got != want {
+		t.Errorf("<details> tags = %d, </details> tags = %d, want equal", got, want)
+	}
+
+	// Rendering again gives the same result.
+	if diff := cmp.Diff(s.AsHTML(), strings.TrimPrefix(want, scrolledOut.String())); diff != "" {
+		t.Errorf("second s.AsHTML() diff (-got +want):\n%s", diff)
+	}
+}
+
+func TestSectionsLineNumbers(t *testing.T) {
+	const input = "::group::a\nb\n::endgroup::\n::group::c\nd\n::endgroup::\ne\nf"
+	opts := HTMLOptions{Sections: true, LineAnchors: true}
+	idRE := regexp.MustCompile(`id="(L[0-9]+)"`)
+
+	tests := []struct {
+		name          string
+		maxLines      int
+		scrollOutFunc bool
+		want          []string
+	}{
+		{name: "rendered together", want: []string{"L1", "L2", "L3", "L4", "L5", "L6"}},
+		{name: "streamed", maxLines: 2, scrollOutFunc: true, want: []string{"L1", "L2", "L3", "L4", "L5", "L6"}},
+		{name: "dropped", maxLines: 2, want: []string{"L5", "L6"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			s, err := NewScreen(WithMaxSize(0, test.maxLines), WithHTMLOptions(opts))
+			if err != nil {
+				t.Fatalf("NewScreen() = %v", err)
+			}
+			var scrolledOut strings.Builder
+			if test.scrollOutFunc {
+				s.ScrollOutFunc = func(line string) { scrolledOut.WriteString(line) }
+			}
+			s.Write([]byte(input))
+
+			var got []string
+			for _, m := range idRE.FindAllStringSubmatch(scrolledOut.String()+s.AsHTML(), -1) {
+				got = append(got, m[1])
+			}
+			if diff := cmp.Diff(got, test.want); diff != "" {
+				t.Errorf("line anchors diff (-got +want):\n%s", diff)
+			}
+		})
+	}
+}
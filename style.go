@@ -1,12 +1,16 @@
 package terminal
 
-import "strconv"
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
 
 type style uint64
 
 // style encoding:
-// 0... ...23  24... ...47  48...57     58     59   60....63
-// [fg color]  [bg color]   [flags]  element  link  [unused]
+// 0... ...23  24... ...47  48...57     58     59     60       61         62        63
+// [fg color]  [bg color]   [flags]  element  link  reverse  conceal  wide tail  [unused]
 // flags = bold, faint, etc
 
 const (
@@ -22,6 +26,9 @@ const (
 	sbBlink
 	sbElement   // meaning: this node is actually an element
 	sbHyperlink // this node is styled with an OSC 8 (iTerm-style) link
+	sbReverse   // reverse video: swap fg and bg colours
+	sbConceal   // concealed (hidden) text
+	sbWideTail  // this node is the second column of a wide character
 )
 
 const (
@@ -37,8 +44,9 @@ const (
 )
 
 
-// Used for comparing styles - ignores the element bit, link bit, and unused bits.
-const styleComparisonMask = 0x03ff_ffff_ffff_ffff
+// Used for comparing styles - ignores the element bit, link bit, wide tail
+// bit, and unused bits.
+const styleComparisonMask = 0x33ff_ffff_ffff_ffff
 
 // isPlain reports if there is no style information. elements (that have no
 // other style set) are also considered plain.
@@ -55,7 +63,10 @@ func (s style) underline() bool  { return s&sbUnderline != 0 }
 func (s style) strike() bool     { return s&sbStrike != 0 }
 func (s style) blink() bool      { return s&sbBlink != 0 }
 func (s style) element() bool    { return s&sbElement != 0 }
+func (s style) reverse() bool    { return s&sbReverse != 0 }
+func (s style) conceal() bool    { return s&sbConceal != 0 }
 func (s style) hyperlink() bool  { return s&sbHyperlink != 0 }
+func (s style) wideTail() bool   { return s&sbWideTail != 0 }
 
 func (s *style) resetFGColor()  { *s = (*s &^ 0x3_0000_00ff_ffff) }
 func (s *style) setFGColorSGR(v uint8)  { *s = (*s &^ 0x3_0000_00ff_ffff) | style(v) | (style(colorSGR) << 48) }
@@ -75,6 +86,8 @@ func (s *style) setStrike(v bool)    { *s = (*s &^ sbStrike) | booln(v, sbStrike
 func (s *style) setBlink(v bool)     { *s = (*s &^ sbBlink) | booln(v, sbBlink) }
 func (s *style) setElement(v bool)   { *s = (*s &^ sbElement) | booln(v, sbElement) }
 func (s *style) setHyperlink(v bool) { *s = (*s &^ sbHyperlink) | booln(v, sbHyperlink) }
+func (s *style) setReverse(v bool)   { *s = (*s &^ sbReverse) | booln(v, sbReverse) }
+func (s *style) setConceal(v bool)   { *s = (*s &^ sbConceal) | booln(v, sbConceal) }
 
 const (
 	COLOR_NORMAL   = iota
@@ -86,38 +99,55 @@ const (
 	COLOR_GOT_48   = iota
 )
 
+// colors returns the fg and bg colours to display, which are swapped if the
+// style is reversed. SGR colours are converted between their fg and bg codes
+// (e.g. 31 <-> 41) when swapped.
+func (s style) colors() (fgType uint8, fg uint32, bgType uint8, bg uint32) {
+	fgType, fg, bgType, bg = s.fgColorType(), s.fgColor(), s.bgColorType(), s.bgColor()
+	if !s.reverse() {
+		return fgType, fg, bgType, bg
+	}
+	if fgType == colorSGR {
+		fg += 10
+	}
+	if bgType == colorSGR {
+		bg -= 10
+	}
+	return bgType, bg, fgType, fg
+}
+
 // CSS classes that make up the style
 func (s style) asClasses() []string {
 	var styles []string
 
-	switch s.fgColorType() {
+	fgType, fg, bgType, bg := s.colors()
+
+	switch fgType {
 	case colorSGR:
-		if s.fgColor() > 29 && s.fgColor() < 38 {
-			styles = append(styles, "term-fg"+strconv.Itoa(int(s.fgColor())))
+		if fg > 29 && fg < 38 {
+			styles = append(styles, "term-fg"+strconv.Itoa(int(fg)))
 		}
-		if s.fgColor() > 89 && s.fgColor() < 98 {
-			styles = append(styles, "term-fgi"+strconv.Itoa(int(s.fgColor())))
+		if fg > 89 && fg < 98 {
+			styles = append(styles, "term-fgi"+strconv.Itoa(int(fg)))
 		}
 	case color8Bit:
-		styles = append(styles, "term-fgx"+strconv.Itoa(int(s.fgColor())))
+		styles = append(styles, "term-fgx"+strconv.Itoa(int(fg)))
 	case color24Bit:
-		// This should set the fg color to the specified color, but the previous behavior was
-		// undefined and implementing that is outside the scope of this PR.
+		// 24-bit colours can't be expressed as classes, see asCSS.
 	}
 
-	switch s.bgColorType() {
+	switch bgType {
 	case colorSGR:
-		if s.bgColor() > 39 && s.bgColor() < 48 {
-			styles = append(styles, "term-bg"+strconv.Itoa(int(s.bgColor())))
+		if bg > 39 && bg < 48 {
+			styles = append(styles, "term-bg"+strconv.Itoa(int(bg)))
 		}
-		if s.bgColor() > 99 && s.bgColor() < 108 {
-			styles = append(styles, "term-bgi"+strconv.Itoa(int(s.bgColor())))
+		if bg > 99 && bg < 108 {
+			styles = append(styles, "term-bgi"+strconv.Itoa(int(bg)))
 		}
 	case color8Bit:
-		styles = append(styles, "term-bgx"+strconv.Itoa(int(s.bgColor())))
+		styles = append(styles, "term-bgx"+strconv.Itoa(int(bg)))
 	case color24Bit:
-		// This should set the bg color to the specified color, but the previous behavior was
-		// undefined and implementing that is outside the scope of this PR.
+		// 24-bit colours can't be expressed as classes, see asCSS.
 	}
 
 	if s.bold() {
@@ -135,6 +165,13 @@ func (s style) asClasses() []string {
 	if s.blink() {
 		styles = append(styles, "term-fg5")
 	}
+	if s.reverse() {
+		// Swaps the default colours. Explicit colours (swapped above) override it.
+		styles = append(styles, "term-fg7")
+	}
+	if s.conceal() {
+		styles = append(styles, "term-fg8")
+	}
 	if s.strike() {
 		styles = append(styles, "term-fg9")
 	}
@@ -142,6 +179,77 @@ func (s style) asClasses() []string {
 	return styles
 }
 
+// CSS declarations that make up the parts of the style that can't be
+// expressed as classes (24-bit colours).
+func (s style) asCSS() []string {
+	var decls []string
+	fgType, fg, bgType, bg := s.colors()
+	if fgType == color24Bit {
+		decls = append(decls, "color:"+rgbHex(fg))
+	}
+	if bgType == color24Bit {
+		decls = append(decls, "background-color:"+rgbHex(bg))
+	}
+	return decls
+}
+
+// CSS declarations that make up the whole style, resolving colours with the
+// theme. This is the inline-style equivalent of asClasses + asCSS, and
+// mirrors the rules in terminal.css.
+func (s style) asInlineCSS(t *Theme) []string {
+	var decls []string
+
+	fg, bg := s.themeColors(t)
+	if fg != "" {
+		decls = append(decls, "color:"+fg)
+	}
+	if bg != "" {
+		decls = append(decls, "background-color:"+bg)
+	}
+
+	// Bold is deliberately not rendered, as in terminal.css. Blink is also
+	// left out, as it needs a keyframes rule.
+	if s.italic() {
+		decls = append(decls, "font-style:italic")
+	}
+	switch {
+	case s.underline() && s.strike():
+		decls = append(decls, "text-decoration:underline line-through")
+	case s.underline():
+		decls = append(decls, "text-decoration:underline")
+	case s.strike():
+		decls = append(decls, "text-decoration:line-through")
+	}
+
+	return decls
+}
+
+// themeColors returns the fg and bg colours for the style from the theme, or
+// "" for the default colours.
+func (s style) themeColors(t *Theme) (fg, bg string) {
+	fgType, fgValue, bgType, bgValue := s.colors()
+	fg, bg = t.color(fgType, fgValue), t.color(bgType, bgValue)
+	if fg == "" && s.faint() {
+		// faint (decreased intensity) - same as grey really
+		fg = t.Colors[8]
+	}
+	if s.reverse() {
+		// Reversing also swaps the default colours.
+		if fg == "" {
+			fg = t.Background
+		}
+		if bg == "" {
+			bg = t.Foreground
+		}
+	}
+	return fg, bg
+}
+
+// rgbHex formats a 24-bit colour (0xRRGGBB) as a CSS hex colour (#rrggbb).
+func rgbHex(c uint32) string {
+	return fmt.Sprintf("#%06x", c&0xff_ffff)
+}
+
 // Add colours to an existing style, returning a new style.
 func (s style) color(colors []string) style {
 	if len(colors) == 0 || (len(colors) == 1 && (colors[0] == "0" || colors[0] == "")) {
@@ -154,6 +262,14 @@ func (s style) color(colors []string) style {
 	var rgb_index uint8
 
 	for _, ccs := range colors {
+		// Colon-separated sub-parameters (e.g. 38:2::255:0:0) are self-contained,
+		// so they also interrupt any semicolon-separated colour in progress.
+		if strings.Contains(ccs, ":") {
+			s = s.colorSubparams(strings.Split(ccs, ":"))
+			colorMode = COLOR_NORMAL
+			continue
+		}
+
 		// If multiple colors are defined, i.e. \e[30;42m\e then loop through each
 		// one, and assign it to s.fgColor or s.bgColor
 		cc, err := strconv.ParseUint(ccs, 10, 8)
@@ -195,7 +311,7 @@ func (s style) color(colors []string) style {
 			continue
 		case COLOR_GOT_38_2:
 			rgb[rgb_index] = uint8(cc)
-			if rgb_index == 3 {
+			if rgb_index == 2 {
 				s.setFGColor24Bit(rgb)
 				colorMode = COLOR_NORMAL
 				continue
@@ -204,7 +320,7 @@ func (s style) color(colors []string) style {
 			continue
 		case COLOR_GOT_48_2:
 			rgb[rgb_index] = uint8(cc)
-			if rgb_index == 3 {
+			if rgb_index == 2 {
 				s.setBGColor24Bit(rgb)
 				colorMode = COLOR_NORMAL
 				continue
@@ -229,6 +345,10 @@ func (s style) color(colors []string) style {
 			s.setUnderline(true)
 		case 5, 6:
 			s.setBlink(true)
+		case 7:
+			s.setReverse(true)
+		case 8:
+			s.setConceal(true)
 		case 9:
 			s.setStrike(true)
 		case 21, 22:
@@ -240,6 +360,10 @@ func (s style) color(colors []string) style {
 			s.setUnderline(false)
 		case 25:
 			s.setBlink(false)
+		case 27:
+			s.setReverse(false)
+		case 28:
+			s.setConceal(false)
 		case 29:
 			s.setStrike(false)
 		case 38:
@@ -259,6 +383,68 @@ func (s style) color(colors []string) style {
 	return s
 }
 
+// colorSubparams applies a single SGR parameter written in the colon-separated
+// form from ITU T.416, e.g. 38:5:150, 38:2::255:128:0, or 4:3.
+func (s style) colorSubparams(sub []string) style {
+	switch sub[0] {
+	case "38", "48":
+		if len(sub) < 3 {
+			return s
+		}
+		args := sub[2:]
+		switch sub[1] {
+		case "5":
+			cc, err := strconv.ParseUint(args[0], 10, 8)
+			if err != nil {
+				return s
+			}
+			if sub[0] == "38" {
+				s.setFGColor8Bit(uint8(cc))
+			} else {
+				s.setBGColor8Bit(uint8(cc))
+			}
+
+		case "2":
+			// The standard form has a colour space ID before the components
+			// (usually empty, as in 38:2::r:g:b), but plenty of programs
+			// leave it out entirely (38:2:r:g:b).
+			if len(args) > 3 {
+				args = args[1:4]
+			}
+			if len(args) != 3 {
+				return s
+			}
+			var rgb [3]uint8
+			for i, a := range args {
+				if a == "" {
+					continue
+				}
+				cc, err := strconv.ParseUint(a, 10, 8)
+				if err != nil {
+					return s
+				}
+				rgb[i] = uint8(cc)
+			}
+			if sub[0] == "38" {
+				s.setFGColor24Bit(rgb)
+			} else {
+				s.setBGColor24Bit(rgb)
+			}
+		}
+
+	case "4":
+		// Underline styles (4:3 is "curly" etc). We only have one kind of
+		// underline, and 4:0 turns it off.
+		s.setUnderline(len(sub) < 2 || sub[1] != "0")
+
+	default:
+		// Anything else we know about doesn't use sub-parameters, so just use
+		// the first one.
+		s = s.color(sub[:1])
+	}
+	return s
+}
+
 // false, true => 0, t
 func booln(b bool, t style) style {
 	if b {
@@ -0,0 +1,243 @@
+package terminal
+
+import (
+	"fmt"
+	"html"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// SVGOptions control how screen contents are rendered as SVG.
+type SVGOptions struct {
+	// Theme provides the colours. If nil, BuildkiteTheme is used.
+	Theme *Theme
+
+	// URLPolicy, if set, controls which links are kept, as for HTML.
+	URLPolicy *URLPolicy
+}
+
+// The layout of SVG output, in tenths of a pixel. The font size, line height
+// and padding match terminal.css, and the character width is typical of
+// monospace fonts (0.6em).
+const (
+	svgFontSize    = 120
+	svgCharWidth   = 72
+	svgLineHeight  = 200
+	svgBaseline    = 140 // from the top of the line
+	svgPaddingX    = 180
+	svgPaddingY    = 140
+	svgFontFamily  = `"SFMono-Regular", Monaco, Menlo, Consolas, "Liberation Mono", Courier, monospace`
+	svgBorderRound = 50
+)
+
+// AsSVG returns the contents of the current screen buffer as an SVG image,
+// like a screenshot of the terminal. Each line of the screen is laid out on a
+// grid, so wrapped lines stay wrapped. The image is as wide as the longest
+// line.
+func (s *Screen) AsSVG(opts SVGOptions) string {
+	return linesToSVG(s.contents(), opts)
+}
+
+// svgRun is a run of text (or an element rendered as text) on one row.
+type svgRun struct {
+	col, width int // in columns
+	text       string
+	style      style
+	link       string
+}
+
+// plain reports if the run is made of unstyled spaces, which don't need to
+// be drawn at the end of a row.
+func (r svgRun) plain() bool {
+	return r.style.isPlain() && r.link == "" && strings.Trim(r.text, " ") == ""
+}
+
+// linesToSVG renders screen lines as an SVG image.
+func linesToSVG(screen []screenLine, opts SVGOptions) string {
+	t := themeOrDefault(opts.Theme)
+
+	rows := make([][]svgRun, 0, len(screen))
+	cols := 0
+	for _, l := range svgRows(screen) {
+		runs := l.svgRuns(opts.URLPolicy)
+		// Unstyled spaces at the end of the row don't need drawing.
+		for len(runs) > 0 && runs[len(runs)-1].plain() {
+			runs = runs[:len(runs)-1]
+		}
+		if last := len(runs) - 1; last >= 0 && runs[last].style.isPlain() && runs[last].link == "" {
+			trimmed := strings.TrimRight(runs[last].text, " ")
+			runs[last].width -= len(runs[last].text) - len(trimmed)
+			runs[last].text = trimmed
+		}
+		if len(runs) > 0 {
+			last := runs[len(runs)-1]
+			cols = max(cols, last.col+last.width)
+		}
+		rows = append(rows, runs)
+	}
+	// So do empty rows at the end.
+	for len(rows) > 0 && len(rows[len(rows)-1]) == 0 {
+		rows = rows[:len(rows)-1]
+	}
+
+	width := tenths(2*svgPaddingX + cols*svgCharWidth)
+	height := tenths(2*svgPaddingY + len(rows)*svgLineHeight)
+
+	var b strings.Builder
+	fmt.Fprintf(&b, `<svg xmlns="http://www.w3.org/2000/svg" width="%s" height="%s" viewBox="0 0 %[1]s %[2]s">`+"\n", width, height)
+	fmt.Fprintf(&b, `<rect width="100%%" height="100%%" rx="%s" fill="%s"/>`+"\n", tenths(svgBorderRound), html.EscapeString(t.Background))
+	fmt.Fprintf(&b, `<g font-family="%s" font-size="%spx" fill="%s" xml:space="preserve">`+"\n", html.EscapeString(svgFontFamily), tenths(svgFontSize), html.EscapeString(t.Foreground))
+
+	for y, runs := range rows {
+		top := svgPaddingY + y*svgLineHeight
+
+		// Backgrounds go underneath the text.
+		for _, r := range runs {
+			if _, bg := r.style.themeColors(t); bg != "" {
+				fmt.Fprintf(&b, `<rect x="%s" y="%s" width="%s" height="%s" fill="%s"/>`+"\n",
+					tenths(svgPaddingX+r.col*svgCharWidth), tenths(top), tenths(r.width*svgCharWidth), tenths(svgLineHeight), html.EscapeString(bg))
+			}
+		}
+
+		if len(runs) == 0 {
+			continue
+		}
+		fmt.Fprintf(&b, `<text y="%s">`, tenths(top+svgBaseline))
+		for _, r := range runs {
+			if r.link != "" {
+				fmt.Fprintf(&b, `<a href="%s">`, html.EscapeString(r.link))
+			}
+			fmt.Fprintf(&b, `<tspan x="%s"`, tenths(svgPaddingX+r.col*svgCharWidth))
+			for _, attr := range r.style.asSVGAttrs(t) {
+				b.WriteString(" " + attr)
+			}
+			// Control characters aren't allowed in XML.
+			b.WriteString(">" + html.EscapeString(stripControls(r.text)) + "</tspan>")
+			if r.link != "" {
+				b.WriteString("</a>")
+			}
+		}
+		b.WriteString("</text>\n")
+	}
+
+	b.WriteString("</g>\n</svg>\n")
+	return b.String()
+}
+
+// svgRows returns the rows to draw: the screen lines, with the lines of an
+// inlined alternate screen in place of its element.
+func svgRows(screen []screenLine) []screenLine {
+	var rows []screenLine
+	for _, l := range screen {
+		if len(l.nodes) == 1 && l.nodes[0].style.element() {
+			if e := l.elements[l.nodes[0].blob]; e.elementType == elementAltScreen {
+				rows = append(rows, svgRows(e.lines)...)
+				continue
+			}
+		}
+		rows = append(rows, l)
+	}
+	return rows
+}
+
+// svgRuns splits the line into runs of text with the same style and link.
+// Links are checked against the URL policy (which can be nil).
+// Wide characters get a run of their own, so that text after them stays on
+// the grid even if the font's wide characters aren't exactly two columns
+// wide.
+func (l *screenLine) svgRuns(p *URLPolicy) []svgRun {
+	var runs []svgRun
+	var text strings.Builder
+	var current svgRun
+	col := 0
+	flush := func() {
+		if current.width > 0 {
+			current.text = text.String()
+			runs = append(runs, current)
+		}
+		text.Reset()
+		current = svgRun{col: col}
+	}
+
+	for x, n := range l.nodes {
+		if n.style.wideTail() {
+			// Written with the first column.
+			continue
+		}
+
+		var link string
+		if n.style.hyperlink() {
+			link = p.apply(l.hyperlinks[x], URLLink)
+		}
+
+		if n.style.element() {
+			flush()
+			elem := l.elements[n.blob]
+			elemText, elemLink := elem.asANSI()
+			if elemLink != "" {
+				elemLink = p.apply(elemLink, elem.urlKind())
+			}
+			elemText = stripControls(elemText)
+			current.text, current.style, current.link = elemText, n.style, elemLink
+			current.width = utf8.RuneCountInString(elemText)
+			runs = append(runs, current)
+			col += current.width
+			current = svgRun{col: col}
+			continue
+		}
+
+		w := 1
+		if x+1 < len(l.nodes) && l.nodes[x+1].style.wideTail() {
+			w = 2
+		}
+		if w == 2 || !n.hasSameStyle(node{style: current.style}) || link != current.link {
+			flush()
+		}
+		current.style, current.link = n.style, link
+
+		if n.style.conceal() {
+			text.WriteString(strings.Repeat(" ", w))
+		} else {
+			text.WriteRune(n.blob)
+			text.WriteString(l.combining[x])
+		}
+		current.width += w
+		col += w
+		if w == 2 {
+			flush()
+		}
+	}
+	flush()
+	return runs
+}
+
+// asSVGAttrs returns the SVG presentation attributes for the style, other
+// than the background colour (which is drawn as a rect). Like terminal.css,
+// bold and blink are not rendered.
+func (s style) asSVGAttrs(t *Theme) []string {
+	var attrs []string
+	if fg, _ := s.themeColors(t); fg != "" {
+		attrs = append(attrs, `fill="`+html.EscapeString(fg)+`"`)
+	}
+	if s.italic() {
+		attrs = append(attrs, `font-style="italic"`)
+	}
+	switch {
+	case s.underline() && s.strike():
+		attrs = append(attrs, `text-decoration="underline line-through"`)
+	case s.underline():
+		attrs = append(attrs, `text-decoration="underline"`)
+	case s.strike():
+		attrs = append(attrs, `text-decoration="line-through"`)
+	}
+	return attrs
+}
+
+// tenths formats a length in tenths of a pixel.
+func tenths(n int) string {
+	if n%10 == 0 {
+		return strconv.Itoa(n / 10)
+	}
+	return strconv.Itoa(n/10) + "." + strconv.Itoa(n%10)
+}
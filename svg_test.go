@@ -0,0 +1,154 @@
+package terminal
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestScreenAsSVG(t *testing.T) {
+	const (
+		header = `<svg xmlns="http://www.w3.org/2000/svg" width="%s" height="%s" viewBox="0 0 %[1]s %[2]s">
+<rect width="100%%" height="100%%" rx="5" fill="%s"/>
+<g font-family="&#34;SFMono-Regular&#34;, Monaco, Menlo, Consolas, &#34;Liberation Mono&#34;, Courier, monospace" font-size="12px" fill="%s" xml:space="preserve">
+`
+		footer = "</g>\n</svg>\n"
+	)
+
+	tests := []struct {
+		name  string
+		input string
+		theme *Theme
+		want  string
+	}{
+		{
+			name:  "empty",
+			input: "",
+			want:  fmt.Sprintf(header, "36", "28", "#171717", "#ffffff") + footer,
+		},
+		{
+			name:  "plain text",
+			input: "hello\n\nworld!   \n\n",
+			want: fmt.Sprintf(header, "79.2", "88", "#171717", "#ffffff") +
+				`<text y="28"><tspan x="18">hello</tspan></text>` + "\n" +
+				`<text y="68"><tspan x="18">world!</tspan></text>` + "\n" +
+				footer,
+		},
+		{
+			name:  "colours and backgrounds",
+			input: "\x1b[31;3mred\x1b[0m \x1b[7mrev\x1b[0m \x1b[44;4;9m  ",
+			want: fmt.Sprintf(header, "108", "48", "#171717", "#ffffff") +
+				`<rect x="46.8" y="14" width="21.6" height="20" fill="#ffffff"/>` + "\n" +
+				`<rect x="75.6" y="14" width="14.4" height="20" fill="#8db7e0"/>` + "\n" +
+				`<text y="28"><tspan x="18" fill="#ff7070" font-style="italic">red</tspan><tspan x="39.6"> </tspan><tspan x="46.8" fill="#171717">rev</tspan><tspan x="68.4"> </tspan><tspan x="75.6" text-decoration="underline line-through">  </tspan></text>` + "\n" +
+				footer,
+		},
+		{
+			name:  "theme",
+			input: "\x1b[32mgreen",
+			theme: GitHubLightTheme,
+			want: fmt.Sprintf(header, "72", "48", "#ffffff", "#24292f") +
+				`<text y="28"><tspan x="18" fill="#116329">green</tspan></text>` + "\n" +
+				footer,
+		},
+		{
+			name:  "wide characters stay on the grid",
+			input: "日本x",
+			want: fmt.Sprintf(header, "72", "48", "#171717", "#ffffff") +
+				`<text y="28"><tspan x="18">日</tspan><tspan x="32.4">本</tspan><tspan x="46.8">x</tspan></text>` + "\n" +
+				footer,
+		},
+		{
+			name:  "wrapped lines stay wrapped",
+			input: "\x1b[8;24;4tabcdef",
+			want: fmt.Sprintf(header, "64.8", "68", "#171717", "#ffffff") +
+				`<text y="28"><tspan x="18">abcd</tspan></text>` + "\n" +
+				`<text y="48"><tspan x="18">ef</tspan></text>` + "\n" +
+				footer,
+		},
+		{
+			name:  "text is escaped",
+			input: `<a href="x">&</a>`,
+			want: fmt.Sprintf(header, "158.4", "48", "#171717", "#ffffff") +
+				`<text y="28"><tspan x="18">&lt;a href=&#34;x&#34;&gt;&amp;&lt;/a&gt;</tspan></text>` + "\n" +
+				footer,
+		},
+		{
+			name:  "hyperlinks",
+			input: "\x1b]8;;http://example.com/?a=1&b=2\x1b\\link\x1b]8;;\x1b\\ \x1b]8;;javascript:alert(1)\x1b\\bad",
+			want: fmt.Sprintf(header, "93.6", "48", "#171717", "#ffffff") +
+				`<text y="28"><a href="http://example.com/?a=1&amp;b=2"><tspan x="18">link</tspan></a><tspan x="46.8"> </tspan><a href="#"><tspan x="54">bad</tspan></a></text>` + "\n" +
+				footer,
+		},
+		{
+			name:  "control characters are removed",
+			input: "a\x01b\x7fc",
+			want: fmt.Sprintf(header, "72", "48", "#171717", "#ffffff") +
+				`<text y="28"><tspan x="18">abc</tspan></text>` + "\n" +
+				footer,
+		},
+		{
+			name:  "link element",
+			input: "\x1b]1339;url=http://example.com;content=a\x1bb\x07!",
+			want: fmt.Sprintf(header, "57.6", "48", "#171717", "#ffffff") +
+				`<text y="28"><a href="http://example.com"><tspan x="18">ab</tspan></a><tspan x="32.4">!</tspan></text>` + "\n" +
+				footer,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			s, err := NewScreen()
+			if err != nil {
+				t.Fatalf("NewScreen() = %v", err)
+			}
+			s.Write([]byte(test.input))
+			got := s.AsSVG(SVGOptions{Theme: test.theme})
+			if diff := cmp.Diff(got, test.want); diff != "" {
+				t.Errorf("s.AsSVG(%v) diff (-got +want):\n%s", test.theme, diff)
+			}
+		})
+	}
+}
+
+func TestScreenAsSVGAltScreen(t *testing.T) {
+	s, err := NewScreen(WithAltScreenPolicy(AltScreenInline))
+	if err != nil {
+		t.Fatalf("NewScreen() = %v", err)
+	}
+	s.Write([]byte("$ less\n\x1b[?1049h\x1b[Hpage\x1b[?1049l$ done"))
+
+	got := s.AsSVG(SVGOptions{})
+	want := []string{
+		`<text y="28"><tspan x="18">$ less</tspan></text>`,
+		`<text y="48"><tspan x="18">page</tspan></text>`,
+		`<text y="68"><tspan x="18">$ done</tspan></text>`,
+	}
+	for _, w := range want {
+		if !strings.Contains(got, w) {
+			t.Errorf("s.AsSVG(SVGOptions{}) = %q, want it to contain %q", got, w)
+		}
+	}
+}
+
+func TestSVGAgainstFixtures(t *testing.T) {
+	for _, base := range TestFiles {
+		t.Run(fmt.Sprintf("for fixture %q", base), func(t *testing.T) {
+			raw := loadFixture(t, base, "raw")
+			want := string(loadFixture(t, base, "svg"))
+
+			s, err := NewScreen()
+			if err != nil {
+				t.Fatalf("NewScreen() = %v", err)
+			}
+			s.Write(raw)
+			got := s.AsSVG(SVGOptions{})
+
+			if diff := cmp.Diff(got, want); diff != "" {
+				t.Errorf("s.AsSVG(SVGOptions{}) diff (-got +want):\n%s", diff)
+			}
+		})
+	}
+}
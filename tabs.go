@@ -0,0 +1,72 @@
+package terminal
+
+// tabWidth is the distance between the default tab stops.
+const tabWidth = 8
+
+// isTabStop reports if there is a tab stop at column x.
+func (s *Screen) isTabStop(x int) bool {
+	if s.tabStops == nil {
+		return x%tabWidth == 0
+	}
+	return x < len(s.tabStops) && s.tabStops[x]
+}
+
+// setTabStop sets a tab stop at column x (HTS, ESC H).
+func (s *Screen) setTabStop(x int) {
+	if s.tabStops == nil {
+		// Start with the default tab stops.
+		s.tabStops = make([]bool, s.cols)
+		for i := range s.tabStops {
+			s.tabStops[i] = i%tabWidth == 0
+		}
+	}
+	for len(s.tabStops) <= x {
+		s.tabStops = append(s.tabStops, false)
+	}
+	s.tabStops[x] = true
+}
+
+// clearTabStops handles Tab Clear (TBC, CSI g): 0 clears the tab stop at
+// the cursor, and 3 clears all tab stops.
+func (s *Screen) clearTabStops(mode string) {
+	switch mode {
+	case "0", "":
+		if s.isTabStop(s.x) {
+			s.setTabStop(s.x) // materialise the default tab stops
+			s.tabStops[s.x] = false
+		}
+	case "3":
+		s.tabStops = []bool{}
+	}
+}
+
+// tabForward moves the cursor forward to the nth next tab stop (HT, CHT), or
+// the last column if there are no more tab stops. Like a real terminal, the
+// cells in between are not written to, so they are left alone if they
+// already have text, and are rendered as spaces if they don't.
+func (s *Screen) tabForward(n int) {
+	for range n {
+		if s.x >= s.cols-1 {
+			return
+		}
+		s.x++
+		for s.x < s.cols-1 && !s.isTabStop(s.x) {
+			s.x++
+		}
+	}
+}
+
+// tabBackward moves the cursor back to the nth previous tab stop (CBT), or
+// the first column if there are no more tab stops.
+func (s *Screen) tabBackward(n int) {
+	s.x = min(s.x, s.cols-1)
+	for range n {
+		if s.x == 0 {
+			return
+		}
+		s.x--
+		for s.x > 0 && !s.isTabStop(s.x) {
+			s.x--
+		}
+	}
+}
@@ -25,6 +25,7 @@ var TestFiles = []string{
 	"playwright.sh",
 	"pwsh.sh",
 	"rustfmt.sh",
+	"truecolor.sh",
 	"weather.sh",
 }
 
@@ -227,6 +228,146 @@ var rendererTestCases = []struct {
 		input: "meow\npurr\nnyan\x1bMrawr",
 		want:  "meow\npurrrawr\nnyan",
 	},
+	{
+		name:  "handles insert character",
+		input: "hello world\r\x1b[6C\x1b[4@big ",
+		want:  "hello big world",
+	},
+	{
+		name:  "handles delete character",
+		input: "hello big world\r\x1b[6C\x1b[4P",
+		want:  "hello world",
+	},
+	{
+		name:  "handles erase character",
+		input: "hello world\r\x1b[5Xjelly",
+		want:  "jelly world",
+	},
+	{
+		name:  "moves links along with inserted and deleted characters",
+		input: "a \x1b]8;;http://google.com\x1b\\link\x1b]8;;\x1b\\!\r\x1b[3@\x1b[2C\x1b[1P",
+		want:  `  a <a href="http://google.com">link</a>!`,
+	},
+	{
+		name:  "handles insert line",
+		input: "one\ntwo\x1b[A\x1b[Lzero",
+		want:  "zero\none\ntwo",
+	},
+	{
+		name:  "handles delete line",
+		input: "one\ntwo\nthree\x1b[2A\x1b[Mfour",
+		want:  "four\nthree",
+	},
+	{
+		name:  "distinguishes CSI M (delete line) from CSI m (SGR)",
+		input: "one\ntwo\x1b[A\x1b[1M\x1b[1mthree",
+		want:  "<span class=\"term-fg1\">three</span>",
+	},
+	{
+		name:  "handles CSI f like CSI H",
+		input: "hello\x1b[1;3fX",
+		want:  "hello\n  X",
+	},
+	{
+		name:  "counts wide characters as two columns",
+		input: "日本\x1b[5GX",
+		want:  "日本X",
+	},
+	{
+		name:  "overwriting half of a wide character blanks the other half",
+		input: "日本語\r\x1b[2Cx",
+		want:  "日x 語",
+	},
+	{
+		name:  "attaches combining marks to the previous character",
+		input: "cafe\u0301!\x1b[6G?",
+		want:  "cafe\u0301!?",
+	},
+	{
+		name:  "overwriting a character removes its combining marks",
+		input: "e\u0301\rx",
+		want:  "x",
+	},
+	{
+		name:  "keeps emoji joined with zero width joiners in one cell",
+		input: "👩\u200d💻\r\x1b[2C!",
+		want:  "👩\u200d💻!",
+	},
+	{
+		name:  "wide characters in links",
+		input: "\x1b]8;;http://example.com\x1b\\日本\x1b]8;;\x1b\\",
+		want:  `<a href="http://example.com">日本</a>`,
+	},
+	{
+		name:  "expands tabs to the next tab stop",
+		input: "a\tb\n12345678\tc",
+		want:  "a       b\n12345678        c",
+	},
+	{
+		name:  "tabs move the cursor without overwriting",
+		input: "abcdefghij\r\tX",
+		want:  "abcdefghXj",
+	},
+	{
+		name:  "handles cursor horizontal tab and cursor backward tab",
+		input: "\x1b[2Ia\rabcdefghij\x1b[Zb",
+		want:  "abcdefghbj      a",
+	},
+	{
+		name:  "handles setting tab stops",
+		input: "\x1b[3g\x1b[4C\x1bH\r\tx",
+		want:  "    x",
+	},
+	{
+		name:  "handles clearing a tab stop",
+		input: "\x1b[8C\x1b[g\r\tx",
+		want:  "                x",
+	},
+	{
+		name:  "translates DEC special graphics",
+		input: "\x1b(0lqqk\nx  x\nmqqj\x1b(B ok",
+		want:  "┌──┐\n│  │\n└──┘ ok",
+	},
+	{
+		name:  "shifts between G0 and G1 character sets",
+		input: "\x1b)0q\x0eqqq\x0fq",
+		want:  "q───q",
+	},
+	{
+		name:  "restores the style saved with the cursor",
+		input: "\x1b7\x1b[32mdone\x1b8\x1b[4Cnext",
+		want:  `<span class="term-fg32">done</span>next`,
+	},
+	{
+		name:  "handles CSI s and CSI u",
+		input: "\x1b[s\x1b[31mred\x1b[u\x1b[3Cplain",
+		want:  `<span class="term-fg31">red</span>plain`,
+	},
+	{
+		name:  "restores the link saved with the cursor",
+		input: "\x1b7\x1b]8;;http://example.com\x1b\\link\x1b8\x1b[4C!",
+		want:  `<a href="http://example.com">link</a>!`,
+	},
+	{
+		name:  "restores the character set saved with the cursor",
+		input: "\x1b7\x1b(0q\x1b8\x1b[1Cq",
+		want:  "─q",
+	},
+	{
+		name:  "ignores CSI u with parameters",
+		input: "ab\x1b[s\rX\x1b[?uY",
+		want:  "XY",
+	},
+	{
+		name:  "positions the cursor absolutely once the PTY size is declared",
+		input: "\x1b_bk;cols=20;lines=5\x07a\nb\nc\x1b[2;3HX\x1b[5dY\x1b[1fZ",
+		want:  "Z\nb X\nc\n&nbsp;\n   Y",
+	},
+	{
+		name:  "reflows wrapped lines when the window is resized",
+		input: "\x1b[8;5;10tabcdefghijkl\x1b[8;5;4t\r\x1b[AX",
+		want:  "abcdXfghijkl",
+	},
 	{
 		name:  "collapses many spans of the same color into 1",
 		input: "\x1b[90m․\x1b[90m․\x1b[90m․\x1b[90m․\n\x1b[90m․\x1b[90m․\x1b[90m․\x1b[90m․",
@@ -258,9 +399,29 @@ var rendererTestCases = []struct {
 		want:  "<span class=\"term-fgx169 term-bgx50\">hello</span> <span class=\"term-fgx179\">goodbye</span>",
 	},
 	{
-		name:  "doesn't trip over 24-bit colors",
+		name:  "handles 24-bit colors",
 		input: "\x1b[48;5;50;38;2;48;7;1mhello\x1b[0m \x1b[38;5;179;48;2;38;5;200mgoodbye",
-		want:  "<span class=\"term-bgx50\">hello</span> <span class=\"term-fgx179\">goodbye</span>",
+		want:  "<span class=\"term-bgx50\" style=\"color:#300701\">hello</span> <span class=\"term-fgx179\" style=\"background-color:#2605c8\">goodbye</span>",
+	},
+	{
+		name:  "only uses three components for 24-bit colors",
+		input: "\x1b[38;2;255;0;0;1;4mhello",
+		want:  "<span class=\"term-fg1 term-fg4\" style=\"color:#ff0000\">hello</span>",
+	},
+	{
+		name:  "handles 24-bit colors with colons",
+		input: "\x1b[38:2::255:128:0mhello \x1b[48:2:0:0:255mworld",
+		want:  "<span style=\"color:#ff8000\">hello </span><span style=\"color:#ff8000;background-color:#0000ff\">world</span>",
+	},
+	{
+		name:  "handles xterm colors with colons",
+		input: "\x1b[38:5:169;48:5:50mhello",
+		want:  "<span class=\"term-fgx169 term-bgx50\">hello</span>",
+	},
+	{
+		name:  "handles underline styles with colons",
+		input: "\x1b[4:3mcurly\x1b[4:0m plain",
+		want:  "<span class=\"term-fg4\">curly</span> plain",
 	},
 	{
 		name:  "handles non-xterm codes on the same line as xterm colors",
@@ -307,6 +468,21 @@ var rendererTestCases = []struct {
 		input: "\x1b[2mbegin\x1b[22m\r\nend",
 		want:  "<span class=\"term-fg2\">begin</span>\nend",
 	},
+	{
+		name:  "handles reverse video with default colors",
+		input: "\x1b[7mreversed\x1b[27m normal",
+		want:  `<span class="term-fg7">reversed</span> normal`,
+	},
+	{
+		name:  "handles reverse video by swapping colors",
+		input: "\x1b[31;7mred bg\x1b[0m \x1b[7;38;5;169;102mx\x1b[0m \x1b[7;38;2;1;2;3my",
+		want:  `<span class="term-bg41 term-fg7">red bg</span> <span class="term-fgi92 term-bgx169 term-fg7">x</span> <span class="term-fg7" style="background-color:#010203">y</span>`,
+	},
+	{
+		name:  "hides concealed text",
+		input: "password: \x1b[8mhunter2\x1b[28m!",
+		want:  `password: <span class="term-fg8">       </span>!`,
+	},
 	{
 		name:  "ignores cursor show/hide",
 		input: "\x1b[?25ldoing a thing without a cursor\x1b[?25h",
@@ -0,0 +1,272 @@
+package terminal
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Theme is a colour scheme used for rendering. It is used to generate
+// stylesheets (see CSS) and for rendering styles as inline CSS.
+type Theme struct {
+	// Name identifies the theme, e.g. "solarized-dark".
+	Name string
+
+	// Default foreground and background colours.
+	Foreground, Background string
+
+	// The 16 standard colours: black, red, green, yellow, blue, magenta, cyan,
+	// white, and the "bright" (high intensity) versions of each.
+	// All colours are CSS colours, e.g. "#ff7070".
+	Colors [16]string
+}
+
+// Built-in themes.
+var (
+	// BuildkiteTheme matches the colours in terminal.css.
+	BuildkiteTheme = &Theme{
+		Name:       "buildkite",
+		Foreground: "#ffffff",
+		Background: "#171717",
+		Colors: [16]string{
+			"#666666", // black (but we can't use black, so a diff color)
+			"#ff7070", // red
+			"#b0f986", // green
+			"#c6c502", // yellow
+			"#8db7e0", // blue
+			"#f271fb", // magenta
+			"#6bf7ff", // cyan
+			"#ffffff", // white
+			"#838887", // grey
+			"#ff3333", // bright red
+			"#00ff00", // bright green
+			"#fffc67", // bright yellow
+			"#6871ff", // bright blue
+			"#ff76ff", // bright magenta
+			"#60fcff", // bright cyan
+			"#ffffff", // bright white
+		},
+	}
+
+	SolarizedDarkTheme = &Theme{
+		Name:       "solarized-dark",
+		Foreground: "#839496",
+		Background: "#002b36",
+		Colors:     solarizedColors,
+	}
+
+	SolarizedLightTheme = &Theme{
+		Name:       "solarized-light",
+		Foreground: "#657b83",
+		Background: "#fdf6e3",
+		Colors:     solarizedColors,
+	}
+
+	DraculaTheme = &Theme{
+		Name:       "dracula",
+		Foreground: "#f8f8f2",
+		Background: "#282a36",
+		Colors: [16]string{
+			"#21222c", "#ff5555", "#50fa7b", "#f1fa8c",
+			"#bd93f9", "#ff79c6", "#8be9fd", "#f8f8f2",
+			"#6272a4", "#ff6e6e", "#69ff94", "#ffffa5",
+			"#d6acff", "#ff92df", "#a4ffff", "#ffffff",
+		},
+	}
+
+	GitHubLightTheme = &Theme{
+		Name:       "github-light",
+		Foreground: "#24292f",
+		Background: "#ffffff",
+		Colors: [16]string{
+			"#24292f", "#cf222e", "#116329", "#4d2d00",
+			"#0969da", "#8250df", "#1b7c83", "#6e7781",
+			"#57606a", "#a40e26", "#1a7f37", "#633c01",
+			"#218bff", "#a475f9", "#3192aa", "#8c959f",
+		},
+	}
+
+	GitHubDarkTheme = &Theme{
+		Name:       "github-dark",
+		Foreground: "#c9d1d9",
+		Background: "#0d1117",
+		Colors: [16]string{
+			"#484f58", "#ff7b72", "#3fb950", "#d29922",
+			"#58a6ff", "#bc8cff", "#39c5cf", "#b1bac4",
+			"#6e7681", "#ffa198", "#56d364", "#e3b341",
+			"#79c0ff", "#d2a8ff", "#56d4dd", "#ffffff",
+		},
+	}
+
+	// Themes lists all the built-in themes.
+	Themes = []*Theme{
+		BuildkiteTheme,
+		SolarizedDarkTheme,
+		SolarizedLightTheme,
+		DraculaTheme,
+		GitHubLightTheme,
+		GitHubDarkTheme,
+	}
+)
+
+// Solarized uses the same accent colours for light and dark variants.
+var solarizedColors = [16]string{
+	"#073642", "#dc322f", "#859900", "#b58900",
+	"#268bd2", "#d33682", "#2aa198", "#eee8d5",
+	"#002b36", "#cb4b16", "#586e75", "#657b83",
+	"#839496", "#6c71c4", "#93a1a1", "#fdf6e3",
+}
+
+// ThemeByName returns the built-in theme with the given name, or nil if there
+// isn't one.
+func ThemeByName(name string) *Theme {
+	for _, t := range Themes {
+		if t.Name == name {
+			return t
+		}
+	}
+	return nil
+}
+
+// themeOrDefault returns t, or BuildkiteTheme if t is nil.
+func themeOrDefault(t *Theme) *Theme {
+	if t == nil {
+		return BuildkiteTheme
+	}
+	return t
+}
+
+// xtermCubeLevels are the component values used by the 6x6x6 colour cube in
+// the xterm 256-colour palette.
+var xtermCubeLevels = [6]uint8{0x00, 0x5f, 0x87, 0xaf, 0xd7, 0xff}
+
+// sgr returns the CSS colour for an SGR colour code (30-37, 40-47, 90-97, or
+// 100-107), or "" if the code isn't a colour.
+func (t *Theme) sgr(code uint32) string {
+	switch {
+	case code >= 30 && code <= 37:
+		return t.Colors[code-30]
+	case code >= 40 && code <= 47:
+		return t.Colors[code-40]
+	case code >= 90 && code <= 97:
+		return t.Colors[code-90+8]
+	case code >= 100 && code <= 107:
+		return t.Colors[code-100+8]
+	}
+	return ""
+}
+
+// xterm returns the CSS colour for an xterm 256-colour index.
+func (t *Theme) xterm(idx uint32) string {
+	switch {
+	case idx < 16:
+		return t.Colors[idx]
+
+	case idx < 232:
+		// 6x6x6 colour cube
+		idx -= 16
+		r, g, b := xtermCubeLevels[idx/36], xtermCubeLevels[(idx/6)%6], xtermCubeLevels[idx%6]
+		return fmt.Sprintf("#%02x%02x%02x", r, g, b)
+
+	case idx < 256:
+		// 24-step greyscale ramp, excluding black and white
+		v := 8 + 10*(idx-232)
+		return fmt.Sprintf("#%02x%02x%02x", v, v, v)
+	}
+	return ""
+}
+
+// color returns the CSS colour for a colour stored in a style, or "" if the
+// colour is the default.
+func (t *Theme) color(colorType uint8, value uint32) string {
+	switch colorType {
+	case colorSGR:
+		return t.sgr(value)
+	case color8Bit:
+		return t.xterm(value)
+	case color24Bit:
+		return rgbHex(value)
+	}
+	return ""
+}
+
+// themeBaseCSS contains the rules from terminal.css that don't depend on the
+// theme colours.
+const themeBaseCSS = `.term-container {
+  border-radius: 5px;
+  word-break: break-word;
+  overflow-wrap: break-word;
+  font-family: "SFMono-Regular", Monaco, Menlo, Consolas, "Liberation Mono", Courier, monospace;
+  font-size: 12px;
+  line-height: 20px;
+  padding: 14px 18px;
+  white-space: pre-wrap;
+}
+
+.term-container img { max-width: 100%; }
+
+.term-container time { padding-right: 1ex; }
+
+.term-alt-screen > summary { cursor: pointer; opacity: 0.6; }
+.term-section > summary { cursor: pointer; }
+.term-section-muted > summary { opacity: 0.6; }
+
+.term-line:target { background-color: rgba(255, 255, 255, 0.1); }
+.term-line-number {
+  display: inline-block;
+  min-width: 5ch;
+  margin-right: 2ch;
+  text-align: right;
+  opacity: 0.5;
+  user-select: none;
+}
+.term-container a.term-line-number { color: inherit; text-decoration: none; }
+
+.term a { color: inherit; text-decoration: underline; text-decoration-style: dashed; }
+.term a:hover { color: #2882F9 }
+
+@keyframes blink-animation {
+  to {
+    visibility: hidden;
+  }
+}
+
+.term-fg1 { } /* don't bold because it looks weird */
+.term-fg3 { font-style: italic; } /* italic */
+.term-fg4 { text-decoration: underline; } /* underline */
+.term-fg5 { animation: blink-animation 1s steps(3, start) infinite; } /* blink */
+.term-fg9 { text-decoration: line-through; } /* crossed-out */
+`
+
+// CSS generates a stylesheet for the theme. It can be used in place of
+// terminal.css.
+func (t *Theme) CSS() string {
+	var b strings.Builder
+	b.WriteString(themeBaseCSS)
+
+	fmt.Fprintf(&b, "\n.term-container { background: %s; color: %s; }\n", t.Background, t.Foreground)
+	fmt.Fprintf(&b, ".term-fg2 { color: %s; } /* faint (decreased intensity) */\n", t.Colors[8])
+	fmt.Fprintf(&b, ".term-fg7 { color: %s; background: %s; } /* reverse video (with default colors) */\n", t.Background, t.Foreground)
+
+	b.WriteString("\n")
+	for i := range uint32(8) {
+		fmt.Fprintf(&b, ".term-fg%d { color: %s; }\n", 30+i, t.sgr(30+i))
+	}
+	for i := range uint32(8) {
+		fmt.Fprintf(&b, ".term-fgi%d { color: %s; }\n", 90+i, t.sgr(90+i))
+	}
+	for i := range uint32(8) {
+		fmt.Fprintf(&b, ".term-bg%d { background: %s; }\n", 40+i, t.sgr(40+i))
+	}
+	for i := range uint32(8) {
+		fmt.Fprintf(&b, ".term-bgi%d { background: %s; }\n", 100+i, t.sgr(100+i))
+	}
+
+	b.WriteString("\n/* xterm colors */\n")
+	for i := range uint32(256) {
+		fmt.Fprintf(&b, ".term-fgx%d { color: %s; }\n", i, t.xterm(i))
+	}
+	for i := range uint32(256) {
+		fmt.Fprintf(&b, ".term-bgx%d { background: %s; }\n", i, t.xterm(i))
+	}
+	return b.String()
+}
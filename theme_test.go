@@ -0,0 +1,53 @@
+package terminal
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestThemeByName(t *testing.T) {
+	for _, want := range Themes {
+		if got := ThemeByName(want.Name); got != want {
+			t.Errorf("ThemeByName(%q) = %v, want %v", want.Name, got, want)
+		}
+	}
+	if got := ThemeByName("nope"); got != nil {
+		t.Errorf("ThemeByName(%q) = %v, want nil", "nope", got)
+	}
+}
+
+func TestThemeCSS(t *testing.T) {
+	css := SolarizedLightTheme.CSS()
+
+	for _, want := range []string{
+		".term-container { background: #fdf6e3; color: #657b83; }",
+		".term-fg2 { color: #002b36; }",
+		".term-fg31 { color: #dc322f; }",
+		".term-fgi97 { color: #fdf6e3; }",
+		".term-bg44 { background: #268bd2; }",
+		".term-bgi101 { background: #cb4b16; }",
+		".term-fgx1 { color: #dc322f; }",
+		".term-fgx169 { color: #d75faf; }",
+		".term-bgx255 { background: #eeeeee; }",
+	} {
+		if !strings.Contains(css, want) {
+			t.Errorf("SolarizedLightTheme.CSS() does not contain %q", want)
+		}
+	}
+}
+
+func TestInlineStylesWithTheme(t *testing.T) {
+	s, err := NewScreen()
+	if err != nil {
+		t.Fatalf("NewScreen() = %v", err)
+	}
+	s.Write([]byte("\x1b[31;44mred on blue\x1b[0m \x1b[38;5;13mmagenta"))
+
+	got := s.AsHTMLWithOptions(HTMLOptions{InlineStyles: true, Theme: DraculaTheme})
+	want := `<span style="color:#ff5555;background-color:#bd93f9">red on blue</span> <span style="color:#ff92df">magenta</span>`
+	if diff := cmp.Diff(got, want); diff != "" {
+		t.Errorf("s.AsHTMLWithOptions(HTMLOptions{InlineStyles: true, Theme: DraculaTheme}) diff (-got +want):\n%s", diff)
+	}
+}
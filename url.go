@@ -1,7 +1,10 @@
 package terminal
 
 import (
+	"html"
 	"net/url"
+	"slices"
+	"strings"
 )
 
 const unsafeURLSubstitution = "#"
@@ -20,6 +23,7 @@ func sanitizeURL(s string) string {
 	// deny-list known-XSS-dangerous URL schemes for <a href=""> etc.
 	// An allow-list would be preferable, but we don't know what URL schemes are being legitimately
 	// used in the wild, so that would be a breaking change, and likely require configurability.
+	// (See URLPolicy for the configurable allow-list.)
 	disallowedSchemes := []string{"javascript"}
 	for _, ds := range disallowedSchemes {
 		if url.Scheme == ds {
@@ -30,3 +34,129 @@ func sanitizeURL(s string) string {
 	// default allow
 	return url.String()
 }
+
+// URLKind is the kind of thing a URL is used for.
+type URLKind int
+
+const (
+	// URLLink is the URL of a link (OSC 8, 1339, or found by Linkify).
+	URLLink URLKind = iota
+
+	// URLImage is the URL of an image (1338).
+	URLImage
+)
+
+// URLPolicy controls which URLs in the output can be linked to or embedded,
+// and how links are rendered. The zero value allows any URL except those with
+// the javascript: scheme, as without a policy.
+//
+// The policy applies to every output format. Disallowed URLs are replaced
+// with "#", except that in HTML, disallowed images aren't shown. Images
+// included in the output as data (iTerm2's 1337 sequence) aren't subject to
+// the policy, unless they were stored by an ImageSink.
+type URLPolicy struct {
+	// AllowedSchemes lists the schemes (such as "https") that absolute URLs
+	// can have. If empty, any scheme except javascript: is allowed. Relative
+	// URLs are always allowed, unless AllowedHosts rules them out.
+	AllowedSchemes []string
+
+	// AllowedHosts lists the hosts that URLs can point to. A host of the form
+	// "*.example.com" allows any subdomain of example.com. If empty, any host
+	// is allowed. If not, URLs with no host (such as mailto: and relative
+	// URLs) are only allowed if AllowedHosts includes "".
+	AllowedHosts []string
+
+	// Rel and Target, if set, are the rel and target attributes of links, such
+	// as "nofollow noopener" and "_blank".
+	Rel, Target string
+
+	// Rewrite, if set, is called with each URL that is allowed, and returns
+	// the URL to use instead (for example, to proxy images). Returning ""
+	// disallows the URL.
+	Rewrite func(url string, kind URLKind) string
+}
+
+// apply returns the URL to use in place of raw, or unsafeURLSubstitution if
+// it isn't allowed. A nil policy uses sanitizeURL.
+func (p *URLPolicy) apply(raw string, kind URLKind) string {
+	if p == nil {
+		return sanitizeURL(raw)
+	}
+	u := sanitizeURL(raw)
+	if u == unsafeURLSubstitution {
+		return u
+	}
+	parsed, err := url.Parse(u)
+	if err != nil {
+		return unsafeURLSubstitution
+	}
+	if parsed.Scheme != "" && len(p.AllowedSchemes) > 0 && !slices.ContainsFunc(p.AllowedSchemes, func(s string) bool {
+		return strings.EqualFold(s, parsed.Scheme)
+	}) {
+		return unsafeURLSubstitution
+	}
+	if len(p.AllowedHosts) > 0 && !p.hostAllowed(parsed.Hostname()) {
+		return unsafeURLSubstitution
+	}
+	if p.Rewrite != nil {
+		if u = p.Rewrite(u, kind); u == "" {
+			return unsafeURLSubstitution
+		}
+	}
+	return u
+}
+
+// hostAllowed reports if the host is in AllowedHosts.
+func (p *URLPolicy) hostAllowed(host string) bool {
+	host = strings.ToLower(host)
+	for _, h := range p.AllowedHosts {
+		h = strings.ToLower(h)
+		if suffix, ok := strings.CutPrefix(h, "*."); ok {
+			if strings.HasSuffix(host, "."+suffix) {
+				return true
+			}
+			continue
+		}
+		if host == h {
+			return true
+		}
+	}
+	return false
+}
+
+// linkAttrs returns the rel and target attributes for links (with a leading
+// space), if the policy has them.
+func (p *URLPolicy) linkAttrs() string {
+	if p == nil {
+		return ""
+	}
+	var attrs string
+	if p.Rel != "" {
+		attrs += ` rel="` + html.EscapeString(p.Rel) + `"`
+	}
+	if p.Target != "" {
+		attrs += ` target="` + html.EscapeString(p.Target) + `"`
+	}
+	return attrs
+}
+
+// safeSchemes are the URL schemes allowed by HTMLOptions.Safe, for each kind
+// of URL.
+var safeSchemes = map[URLKind][]string{
+	URLLink:  {"http", "https", "mailto"},
+	URLImage: {"http", "https"},
+}
+
+// url returns the URL to use in place of raw, following the URL policy and
+// safe mode, or unsafeURLSubstitution if it isn't allowed.
+func (o HTMLOptions) url(raw string, kind URLKind) string {
+	u := o.URLPolicy.apply(raw, kind)
+	if !o.Safe || u == unsafeURLSubstitution {
+		return u
+	}
+	parsed, err := url.Parse(u)
+	if err != nil || (parsed.Scheme != "" && !slices.Contains(safeSchemes[kind], strings.ToLower(parsed.Scheme))) {
+		return unsafeURLSubstitution
+	}
+	return u
+}
@@ -2,7 +2,11 @@ package terminal
 
 import (
 	"fmt"
+	"net/url"
+	"strings"
 	"testing"
+
+	"github.com/google/go-cmp/cmp"
 )
 
 func TestSanitizeURL(t *testing.T) {
@@ -38,3 +42,158 @@ func TestSanitizeURL(t *testing.T) {
 		})
 	}
 }
+
+func TestURLPolicy(t *testing.T) {
+	strict := &URLPolicy{
+		AllowedSchemes: []string{"http", "HTTPS"},
+		AllowedHosts:   []string{"example.com", "*.buildkite.com", ""},
+	}
+	rewrite := &URLPolicy{
+		Rewrite: func(u string, kind URLKind) string {
+			switch {
+			case strings.Contains(u, "blocked"):
+				return ""
+			case kind == URLImage:
+				return "https://proxy.example.com/?url=" + url.QueryEscape(u)
+			}
+			return u
+		},
+	}
+
+	tests := []struct {
+		name   string
+		policy *URLPolicy
+		input  string
+		kind   URLKind
+		want   string
+	}{
+		{name: "nil policy", policy: nil, input: "data:text/html,hi", want: "data:text/html,hi"},
+		{name: "nil policy javascript", policy: nil, input: "javascript:alert(1)", want: "#"},
+		{name: "empty policy", policy: &URLPolicy{}, input: "ftp://example.com/", want: "ftp://example.com/"},
+		{name: "empty policy javascript", policy: &URLPolicy{}, input: "javascript:alert(1)", want: "#"},
+
+		{name: "allowed scheme and host", policy: strict, input: "https://example.com/a", want: "https://example.com/a"},
+		{name: "schemes are case-insensitive", policy: strict, input: "HTTPS://example.com/a", want: "https://example.com/a"},
+		{name: "subdomain", policy: strict, input: "http://a.b.buildkite.com/", want: "http://a.b.buildkite.com/"},
+		{name: "wildcard doesn't match the bare domain", policy: strict, input: "http://buildkite.com/", want: "#"},
+		{name: "disallowed host", policy: strict, input: "https://example.com.evil.test/", want: "#"},
+		{name: "data", policy: strict, input: "data:text/html;base64,PHNjcmlwdD4=", want: "#"},
+		{name: "vbscript", policy: strict, input: "vbscript:msgbox(1)", want: "#"},
+		{name: "file", policy: strict, input: "file:///etc/passwd", want: "#"},
+		{name: "relative", policy: strict, input: "/artifacts/a.txt", want: "/artifacts/a.txt"},
+		{name: "scheme-relative", policy: strict, input: "//evil.test/a", want: "#"},
+
+		{name: "rewritten image", policy: rewrite, input: "https://example.com/a.png", kind: URLImage, want: "https://proxy.example.com/?url=https%3A%2F%2Fexample.com%2Fa.png"},
+		{name: "link not rewritten", policy: rewrite, input: "https://example.com/a.png", kind: URLLink, want: "https://example.com/a.png"},
+		{name: "blocked by rewrite", policy: rewrite, input: "https://example.com/blocked", want: "#"},
+		{name: "rewrite isn't given disallowed URLs", policy: rewrite, input: "javascript:alert(1)", kind: URLImage, want: "#"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := test.policy.apply(test.input, test.kind); got != test.want {
+				t.Errorf("policy.apply(%q, %d) = %q, want %q", test.input, test.kind, got, test.want)
+			}
+		})
+	}
+}
+
+func TestScreenAsHTMLWithURLPolicy(t *testing.T) {
+	policy := &URLPolicy{
+		AllowedSchemes: []string{"https"},
+		Rel:            "nofollow noopener",
+		Target:         "_blank",
+	}
+
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{
+			name:  "OSC 8 link",
+			input: "\x1b]8;;https://example.com/\x1b\\ok\x1b]8;;\x1b\\ \x1b]8;;file:///etc/passwd\x1b\\bad\x1b]8;;\x1b\\",
+			want:  `<a href="https://example.com/" rel="nofollow noopener" target="_blank">ok</a> <a href="#" rel="nofollow noopener" target="_blank">bad</a>`,
+		},
+		{
+			name:  "1339 link",
+			input: "\x1b]1339;url=vbscript:msgbox(1);content=bad\x07",
+			want:  `<a href="#" rel="nofollow noopener" target="_blank">bad</a>`,
+		},
+		{
+			name:  "1338 image",
+			input: "\x1b]1338;url=https://example.com/a.png\x07\x1b]1338;url=data:image/svg+xml,x\x07",
+			want:  `<img alt="https://example.com/a.png" src="https://example.com/a.png">` + "\n&nbsp;",
+		},
+		{
+			name:  "linkified URL",
+			input: "see http://example.com",
+			want:  `see <a href="#" rel="nofollow noopener" target="_blank">http:&#47;&#47;example.com</a>`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			s, err := NewScreen()
+			if err != nil {
+				t.Fatalf("NewScreen() = %v", err)
+			}
+			s.Write([]byte(test.input))
+
+			opts := HTMLOptions{URLPolicy: policy, Linkify: LinkifyOptions{URLs: true}}
+			if diff := cmp.Diff(s.AsHTMLWithOptions(opts), test.want); diff != "" {
+				t.Errorf("s.AsHTMLWithOptions(%+v) diff (-got +want):\n%s", opts, diff)
+			}
+		})
+	}
+}
+
+func TestURLPolicyOtherFormats(t *testing.T) {
+	policy := &URLPolicy{
+		AllowedHosts: []string{"example.com"},
+		Rewrite: func(url string, kind URLKind) string {
+			if kind == URLImage {
+				return "https://proxy.example.com/?url=" + url
+			}
+			return url
+		},
+	}
+	const input = "\x1b]8;;https://example.com/\x1b\\ok\x1b]8;;\x1b\\ \x1b]8;;https://evil.com/\x1b\\bad\x1b]8;;\x1b\\\n" +
+		"\x1b]1339;url=https://evil.com/;content=bad\x07\n" +
+		"\x1b]1338;url=https://example.com/a.png;alt=a\x07"
+
+	s, err := NewScreen(WithHTMLOptions(HTMLOptions{URLPolicy: policy}))
+	if err != nil {
+		t.Fatalf("NewScreen() = %v", err)
+	}
+	s.Write([]byte(input))
+
+	wantJSON := `{"runs":[{"text":"ok","link":"https://example.com/"},{"text":" "},{"text":"bad","link":"#"}]}
+{"runs":[{"element":{"type":"link","url":"#","content":"bad"}}]}
+{"runs":[{"element":{"type":"image","url":"https://proxy.example.com/?url=https://example.com/a.png","alt":"a"}}]}
+`
+	if diff := cmp.Diff(s.AsJSON(), wantJSON); diff != "" {
+		t.Errorf("s.AsJSON() diff (-got +want):\n%s", diff)
+	}
+
+	wantANSI := "\x1b]8;;https://example.com/\x1b\\ok\x1b]8;;\x1b\\ \x1b]8;;#\x1b\\bad\x1b]8;;\x1b\\\n" +
+		"\x1b]8;;#\x1b\\bad\x1b]8;;\x1b\\\n" +
+		"\x1b]8;;https://proxy.example.com/?url=https://example.com/a.png\x1b\\[image: a]\x1b]8;;\x1b\\\n"
+	if diff := cmp.Diff(s.AsANSI(), wantANSI); diff != "" {
+		t.Errorf("s.AsANSI() diff (-got +want):\n%s", diff)
+	}
+
+	svg := s.AsFormat(FormatSVG)
+	for _, want := range []string{
+		`<a href="https://example.com/">`,
+		`<a href="#"><tspan x="39.6">bad</tspan></a>`,
+		`<a href="https://proxy.example.com/?url=https://example.com/a.png">`,
+	} {
+		if !strings.Contains(svg, want) {
+			t.Errorf("s.AsFormat(FormatSVG) = %q, want it to contain %q", svg, want)
+		}
+	}
+	if strings.Contains(svg, "evil.com") {
+		t.Errorf("s.AsFormat(FormatSVG) = %q, want no links to evil.com", svg)
+	}
+}
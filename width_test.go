@@ -0,0 +1,52 @@
+package terminal
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestRuneWidth(t *testing.T) {
+	tests := []struct {
+		r    rune
+		want int
+	}{
+		{'a', 1},
+		{'é', 1},
+		{'\u0301', 0}, // combining acute accent
+		{'\u200d', 0}, // zero width joiner
+		{'\ufe0f', 0}, // variation selector 16
+		{'日', 2},
+		{'한', 2},
+		{'ア', 2},
+		{'ｱ', 1}, // halfwidth katakana
+		{'Ａ', 2}, // fullwidth latin
+		{'👩', 2},
+		{'🏽', 0}, // skin tone modifier
+		{'→', 1},
+	}
+	for _, test := range tests {
+		if got := runeWidth(test.r); got != test.want {
+			t.Errorf("runeWidth(%q) = %d, want %d", test.r, got, test.want)
+		}
+	}
+}
+
+func TestWideCharacterWrapping(t *testing.T) {
+	s, err := NewScreen(WithSize(5, 10))
+	if err != nil {
+		t.Fatalf("NewScreen() error = %v", err)
+	}
+	s.Write([]byte("abcd日本"))
+
+	// 日 doesn't fit in the last column, so it wraps to the next line.
+	if got, want := s.screen[0].asPlain(), "abcd"; got != want {
+		t.Errorf("s.screen[0].asPlain() = %q, want %q", got, want)
+	}
+	if got, want := s.screen[1].asPlain(), "日本\n"; got != want {
+		t.Errorf("s.screen[1].asPlain() = %q, want %q", got, want)
+	}
+	if diff := cmp.Diff([2]int{s.x, s.y}, [2]int{4, 1}); diff != "" {
+		t.Errorf("cursor position diff (-got +want):\n%s", diff)
+	}
+}